@@ -0,0 +1,67 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// zipFile opens the Go shared library in a zip file, such as an
+// Android APK or AAR with a gomobile lib/<abi>/libgojni.so. It
+// reports whether ra is a zip file with shared libraries.
+//
+// If there's more than one Go library, the --apk-lib flag or else
+// the --arch flag picks one.
+func zipFile(ra io.ReaderAt, size int64) (f *File, ok bool, err error) {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, false, nil
+	}
+	var files []*File
+	var names []string
+	for _, zf := range zr.File {
+		if !strings.HasSuffix(zf.Name, ".so") {
+			continue
+		}
+		if *apkLib != "" && zf.Name != *apkLib {
+			continue
+		}
+		b, err := readZipFile(zf)
+		if err != nil {
+			return nil, true, err
+		}
+		f, err := Open(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			// Not Go, presumably.
+			continue
+		}
+		files = append(files, f)
+		names = append(names, zf.Name)
+	}
+	if len(files) == 0 {
+		if *apkLib != "" {
+			return nil, true, fmt.Errorf("no Go library %s in zip file", *apkLib)
+		}
+		return nil, false, nil
+	}
+	f, err = selectArch("zip file", files)
+	if err != nil {
+		return nil, true, fmt.Errorf("%v; or pick a library with --apk-lib from: %s", err, strings.Join(names, ", "))
+	}
+	return f, true, nil
+}
+
+func readZipFile(zf *zip.File) ([]byte, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
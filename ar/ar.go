@@ -15,6 +15,7 @@ type Reader struct {
 	ra        io.ReaderAt
 	off       int64
 	headerBuf []byte
+	longNames []byte // contents of the GNU "//" member, if seen
 }
 
 const (
@@ -98,6 +99,30 @@ func (r *Reader) Next() (*File, error) {
 		f.Size -= int64(n)
 	}
 
+	// GNU (and MinGW and Windows lib.exe) names: "/" is the symbol
+	// index, "//" is the table of long names, "/123" is the name at
+	// offset 123 of that table, and other names end in a slash.
+	switch {
+	case f.Name == "/" || f.Name == "/SYM64/":
+	case f.Name == "//":
+		r.longNames = make([]byte, f.Size)
+		if _, err := r.ra.ReadAt(r.longNames, r.off); err != nil {
+			return nil, err
+		}
+	case strings.HasPrefix(f.Name, "/"):
+		off, err := strconv.Atoi(f.Name[1:])
+		if err != nil || off < 0 || off >= len(r.longNames) {
+			return nil, fmt.Errorf("bogus GNU ar long filename reference %q", f.Name)
+		}
+		name := r.longNames[off:]
+		if i := strings.IndexAny(string(name), "\n\x00"); i >= 0 {
+			name = name[:i]
+		}
+		f.Name = strings.TrimSuffix(string(name), "/")
+	default:
+		f.Name = strings.TrimSuffix(f.Name, "/")
+	}
+
 	f.SectionReader = io.NewSectionReader(r.ra, r.off, f.Size)
 	r.off += f.Size
 	if r.off&1 != 0 {
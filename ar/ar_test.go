@@ -0,0 +1,85 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ar
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// gnuArchive returns a GNU-style archive (as made by GNU ar and
+// MinGW) with a symbol index, a long name table, and members with
+// the given names and contents.
+func gnuArchive(members ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	add := func(name, data string) {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8o%-10d`\n", name, 0, 0, 0, 0644, len(data))
+		buf.WriteString(data)
+		if buf.Len()&1 != 0 {
+			buf.WriteByte('\n')
+		}
+	}
+	add("/", "\x00\x00\x00\x00")
+	var long strings.Builder
+	var names []string
+	for i := 0; i < len(members); i += 2 {
+		name := members[i] + "/"
+		if len(name) > fileLen {
+			names = append(names, fmt.Sprintf("/%d", long.Len()))
+			long.WriteString(name + "\n")
+		} else {
+			names = append(names, name)
+		}
+	}
+	if long.Len() > 0 {
+		add("//", long.String())
+	}
+	for i, name := range names {
+		add(name, members[2*i+1])
+	}
+	return buf.Bytes()
+}
+
+func TestGNUNames(t *testing.T) {
+	a := gnuArchive(
+		"a_really_long_member_name.o", "long",
+		"go.o", "go object",
+		"another_long_member_name.o", "odd",
+	)
+	r, err := NewReader(bytes.NewReader(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, f.Name+"="+string(data))
+	}
+	want := []string{
+		"/=\x00\x00\x00\x00",
+		"//=a_really_long_member_name.o/\nanother_long_member_name.o/\n",
+		"a_really_long_member_name.o=long",
+		"go.o=go object",
+		"another_long_member_name.o=odd",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got members %q; want %q", got, want)
+	}
+}
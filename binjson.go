@@ -0,0 +1,55 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// writeBinJSON writes the bin-json mode's output, which --serve also
+// serves at /bin.json: the rows of the sql mode's Bin table, in the
+// shape Observable's SQLite client returns for SELECT * FROM Bin, so
+// a notebook written against a database file can fetch it instead.
+// Keep this shape stable. It's a JSON array with an object per row,
+// whose keys are the table's column names, in order:
+//
+//	[
+//	{"Func":"main.main","Pkg":"main","What":"text","Size":1234},
+//	...
+//	{"Func":null,"Pkg":null,"What":"TODO","Size":5678}
+//	]
+//
+// Func, Pkg, and What are strings, and Size is a number. NULL values
+// are null. With --arch=all there's also an Arch string, and there's
+// a key for each column selected by --columns.
+func writeBinJSON(w io.Writer, cols []dbColumn, rows [][]any) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[\n")
+	for i, row := range rows {
+		bw.WriteByte('{')
+		for j, c := range cols {
+			if j > 0 {
+				bw.WriteByte(',')
+			}
+			k, _ := json.Marshal(c.name)
+			v, err := json.Marshal(row[j])
+			if err != nil {
+				return err
+			}
+			bw.Write(k)
+			bw.WriteByte(':')
+			bw.Write(v)
+		}
+		bw.WriteByte('}')
+		if i < len(rows)-1 {
+			bw.WriteByte(',')
+		}
+		bw.WriteByte('\n')
+	}
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
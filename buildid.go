@@ -0,0 +1,70 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"io"
+	"strconv"
+)
+
+// buildIDPrefix and buildIDSuffix surround the quoted Go build ID
+// that the linker puts at the start of the text of non-ELF binaries,
+// as the body of the go:buildid function. See cmd/internal/buildid.
+var (
+	buildIDPrefix = []byte("\xff Go build ID: ")
+	buildIDSuffix = []byte("\n \xff")
+)
+
+// buildIDSearchSize is how far into a binary to look for its build
+// ID, as cmd/go does.
+const buildIDSearchSize = 32 << 10
+
+// findBuildID returns the Go build ID at the start of the text of
+// the binary ra, or the empty string if there's none.
+func findBuildID(ra io.ReaderAt, size int64) string {
+	b := make([]byte, min(size, buildIDSearchSize))
+	if _, err := ra.ReadAt(b, 0); err != nil && err != io.EOF {
+		return ""
+	}
+	i := bytes.Index(b, buildIDPrefix)
+	if i < 0 {
+		return ""
+	}
+	quoted, _, ok := bytes.Cut(b[i+len(buildIDPrefix):], buildIDSuffix)
+	if !ok {
+		return ""
+	}
+	id, err := strconv.Unquote(string(quoted))
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// elfBuildID returns the Go build ID from ef's .note.go.buildid
+// section, or the empty string if it has none.
+func elfBuildID(ef *elf.File) string {
+	s := ef.Section(".note.go.buildid")
+	if s == nil {
+		return ""
+	}
+	b, err := s.Data()
+	if err != nil || len(b) < 16 {
+		return ""
+	}
+	// An ELF note: the name and description sizes, the type, the
+	// name "Go\x00\x00", and the description.
+	descsz := ef.ByteOrder.Uint32(b[4:])
+	const goBuildIDNote = 4
+	if ef.ByteOrder.Uint32(b) != 4 || ef.ByteOrder.Uint32(b[8:]) != goBuildIDNote || string(b[12:16]) != "Go\x00\x00" {
+		return ""
+	}
+	if uint64(len(b)) < 16+uint64(descsz) {
+		return ""
+	}
+	return string(b[16 : 16+descsz])
+}
@@ -0,0 +1,279 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bradfitz/shotizam/gosym"
+	"github.com/bradfitz/shotizam/sizes"
+)
+
+// A column is an optional per-function column of the sql, tsv, and
+// json outputs, selected with the --columns flag.
+type column struct {
+	name    string // SQL column name; lowercased for the flag and JSON
+	sqlType string
+	// value returns the column's value for fi's function, or nil
+	// (NULL) if it doesn't apply.
+	value func(fi *funcInfo) any
+}
+
+var allColumns = []*column{
+	{"MetaRatio", "real", metaRatio},
+	{"InlCalls", "int", inlCalls},
+	{"StartLine", "int", startLine},
+	{"DeferReturn", "int", deferReturn},
+	{"FuncID", "text", funcID},
+	{"Asm", "bool", func(fi *funcInfo) any { return fi.fn.IsAsm() }},
+	{"ArgSize", "int", argSize},
+	{"MaxStack", "int", func(fi *funcInfo) any { return fi.fn.MaxStack() }},
+	{"NumPCData", "int", func(fi *funcInfo) any { return fi.fn.NumPCData }},
+	{"NumFuncData", "int", func(fi *funcInfo) any { return fi.fn.NumFuncData }},
+	{"FuncTrunc", "varchar", func(fi *funcInfo) any { return funcTrunc(fi.fn.Name) }},
+	{"Type", "varchar", funcType},
+	{"Recv", "varchar", funcRecv},
+}
+
+// funcInfo is what's known about a function while emitting its records.
+type funcInfo struct {
+	t     *gosym.Table
+	fn    *gosym.Func
+	sizes []whatSize // in emit order
+
+	goVersion string // of the toolchain that built the binary, if known
+
+	colVals []any // lazily computed values of selectedColumns
+}
+
+type whatSize struct {
+	what string
+	size int64
+}
+
+// size returns the sum of fi's sizes for which include reports true.
+func (fi *funcInfo) size(include func(what string) bool) int64 {
+	var sum int64
+	for _, ws := range fi.sizes {
+		if include(ws.what) {
+			sum += ws.size
+		}
+	}
+	return sum
+}
+
+// cols returns the values of the selected columns for fi, which
+// may be nil for records not belonging to a function.
+func (fi *funcInfo) cols() []any {
+	if fi == nil {
+		return make([]any, len(selectedColumns))
+	}
+	if fi.colVals == nil {
+		fi.colVals = make([]any, len(selectedColumns))
+		for i, c := range selectedColumns {
+			fi.colVals[i] = c.value(fi)
+		}
+	}
+	return fi.colVals
+}
+
+// recCols returns the selected columns for fi as a Rec's Cols,
+// named as in the json output.
+func (fi *funcInfo) recCols() []sizes.Col {
+	if fi == nil || len(selectedColumns) == 0 {
+		return nil
+	}
+	cols := make([]sizes.Col, len(selectedColumns))
+	for i, v := range fi.cols() {
+		cols[i] = sizes.Col{Name: strings.ToLower(selectedColumns[i].name), Value: v}
+	}
+	return cols
+}
+
+// selectedColumns are the columns selected by the --columns flag,
+// in order. It's set by parseColumns.
+var selectedColumns []*column
+
+func parseColumns(list string) {
+	if list == "" {
+		return
+	}
+	for _, name := range strings.Split(list, ",") {
+		c := columnNamed(strings.TrimSpace(name))
+		if c == nil {
+			fatalf("unknown column %q; want one of %s", name, columnNames())
+		}
+		selectedColumns = append(selectedColumns, c)
+	}
+}
+
+func columnNamed(name string) *column {
+	for _, c := range allColumns {
+		if strings.EqualFold(c.name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// columnNames returns the lowercased names of all columns, for
+// usage messages.
+func columnNames() string {
+	var names []string
+	for _, c := range allColumns {
+		names = append(names, strings.ToLower(c.name))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// sqlColumnDefs returns the SQL column definitions of the selected
+// columns, each preceded by a comma.
+func sqlColumnDefs() string {
+	var sb strings.Builder
+	for _, c := range selectedColumns {
+		fmt.Fprintf(&sb, ", %s %s", c.name, c.sqlType)
+	}
+	return sb.String()
+}
+
+// sqlValue formats v as a SQL literal.
+func sqlValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return sqlString(v)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case float64:
+		return fmt.Sprintf("%.4g", v)
+	}
+	return fmt.Sprint(v)
+}
+
+// tsvValue formats v as a TSV field.
+func tsvValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return fmt.Sprintf("%.4g", v)
+	}
+	return fmt.Sprint(v)
+}
+
+// metaRatio is the MetaRatio column: the ratio of a function's
+// metadata bytes (everything but its text) to its text bytes.
+func metaRatio(fi *funcInfo) any {
+	text := fi.size(func(what string) bool { return what == "text" })
+	if text == 0 {
+		return nil
+	}
+	meta := fi.size(func(what string) bool { return what != "text" })
+	return float64(meta) / float64(text)
+}
+
+// inlCalls returns the number of distinct functions inlined into fi,
+// or nil if its inline tree can't be read, as before Go 1.18.
+func inlCalls(fi *funcInfo) any {
+	n, ok := fi.fn.NumInlinedCalls()
+	if !ok {
+		return nil
+	}
+	return n
+}
+
+// startLine returns the line of fi's func keyword, or nil if the
+// pclntab predates Go 1.20 and doesn't record it.
+func startLine(fi *funcInfo) any {
+	if fi.fn.StartLine == 0 {
+		return nil
+	}
+	return fi.fn.StartLine
+}
+
+// deferReturn returns the offset of fi's deferreturn call from its
+// entry, or nil if it has no defers that need one or its pclntab
+// predates Go 1.16, whose format records it ambiguously.
+func deferReturn(fi *funcInfo) any {
+	if fi.fn.DeferReturn == 0 {
+		return nil
+	}
+	return int64(fi.fn.DeferReturn)
+}
+
+// funcID returns the name of fi's special runtime function ID, such
+// as "wrapper" or "mcall", or nil for normal functions.
+func funcID(fi *funcInfo) any {
+	id := fi.fn.Raw().FuncID
+	if id == 0 {
+		return nil
+	}
+	return gosym.FuncIDName(int(id), fi.goVersion)
+}
+
+// argSize returns the size of fi's arguments and results, or nil if
+// it's unknown.
+func argSize(fi *funcInfo) any {
+	if fi.fn.ArgSize == gosym.ArgsSizeUnknown {
+		return nil
+	}
+	return fi.fn.ArgSize
+}
+
+// funcTrunc returns the function name name cut at its first closure,
+// so pkg.Foo.func1.2 and all of Foo's other closures are pkg.Foo.
+// Package-level closures, like pkg.glob..func1, are pkg.glob.
+func funcTrunc(name string) string {
+	for i := 0; ; {
+		j := strings.Index(name[i:], ".func")
+		if j < 0 {
+			return name
+		}
+		i += j
+		if rest := name[i+len(".func"):]; rest != "" && '0' <= rest[0] && rest[0] <= '9' {
+			return strings.TrimRight(name[:i], ".")
+		}
+		i++
+	}
+}
+
+// funcType returns the package-qualified receiver type of fi's
+// method, or of the method a closure is in, as pkg.(*Server) for
+// pkg.(*Server).handleFoo.func1, or nil if it's not a method.
+func funcType(fi *funcInfo) any {
+	recv := receiverName(fi.fn)
+	if recv == "" {
+		return nil
+	}
+	return fi.fn.PackageName() + "." + recv
+}
+
+// funcRecv returns the receiver type of fi's method, or of the method
+// a closure is in, as (*Server) for pkg.(*Server).handleFoo.func1, or
+// nil if it's not a method.
+func funcRecv(fi *funcInfo) any {
+	if recv := receiverName(fi.fn); recv != "" {
+		return recv
+	}
+	return nil
+}
+
+// receiverName is like fn.ReceiverName, but for closures returns the
+// receiver of the method they're in, rather than the name of their
+// enclosing func. It returns the empty string if fn isn't a method
+// nor in one.
+func receiverName(fn *gosym.Func) string {
+	if fn.PackageName() == "" {
+		return ""
+	}
+	return (&gosym.Sym{Name: funcTrunc(fn.Name)}).ReceiverName()
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+const (
+	gzipMagic = "\x1f\x8b"
+	xzMagic   = "\xfd7zXZ\x00"
+)
+
+// decompress returns the decompressed contents of ra if it's gzip or
+// xz compressed, reporting whether it was. xz needs the xz command.
+func decompress(ra io.ReaderAt, size int64) (b []byte, ok bool, err error) {
+	var magic [len(xzMagic)]byte
+	if _, err := ra.ReadAt(magic[:], 0); err != nil {
+		return nil, false, nil
+	}
+	r := io.NewSectionReader(ra, 0, size)
+	switch {
+	case string(magic[:len(gzipMagic)]) == gzipMagic:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, true, err
+		}
+		b, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, true, fmt.Errorf("gunzip: %w", err)
+		}
+		return b, true, nil
+	case string(magic[:]) == xzMagic:
+		xzBin, err := exec.LookPath("xz")
+		if err != nil {
+			return nil, true, fmt.Errorf("binary is xz compressed, but xz not found")
+		}
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(xzBin, "--decompress", "--stdout")
+		cmd.Stdin = r
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, true, fmt.Errorf("xz: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return stdout.Bytes(), true, nil
+	}
+	return nil, false, nil
+}
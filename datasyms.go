@@ -0,0 +1,136 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// isStringSym reports whether name is a symbol of the linker's Go
+// string constant data: one of the per-string go.string."..."
+// symbols of older Go releases, or the go:string.* symbol that
+// newer ones put at the start of them all.
+func isStringSym(name string) bool {
+	return strings.HasPrefix(name, "go:string.") || strings.HasPrefix(name, "go.string.")
+}
+
+// itabName returns the concrete and interface types of the itab
+// symbol named name, such as "*main.T,fmt.Stringer" for
+// go.itab.*main.T,fmt.Stringer, and whether name is an itab symbol.
+// Only older Go releases put itabs in the symbol table.
+func itabName(name string) (string, bool) {
+	if s, ok := strings.CutPrefix(name, "go.itab."); ok {
+		return s, true
+	}
+	return strings.CutPrefix(name, "go:itab.")
+}
+
+// isItabSym reports whether name is an itab symbol.
+func isItabSym(name string) bool {
+	_, ok := itabName(name)
+	return ok
+}
+
+// itabPackage returns the package of the concrete type of the itab
+// named name, as returned by itabName.
+func itabPackage(name string) string {
+	concrete, _, _ := strings.Cut(name, ",")
+	return varPackage(strings.TrimLeft(concrete, "*"))
+}
+
+// typeName returns the type described by the runtime type descriptor
+// symbol named name, such as "*main.T" for type:*main.T, and whether
+// name is a type descriptor symbol. The type:* symbol marking the
+// start of all the type descriptors, which is all newer Go releases
+// put in the symbol table, describes the empty type. Generated type
+// functions like type:.eq.main.T aren't type descriptors.
+func typeName(name string) (string, bool) {
+	s, ok := strings.CutPrefix(name, "type:")
+	if !ok {
+		s, ok = strings.CutPrefix(name, "type.")
+	}
+	if !ok || strings.HasPrefix(s, ".") {
+		return "", false
+	}
+	if s == "*" {
+		return "", true
+	}
+	return s, true
+}
+
+// isTypeSym reports whether name is a type descriptor symbol.
+func isTypeSym(name string) bool {
+	_, ok := typeName(name)
+	return ok
+}
+
+// typePackage returns the package defining the type described by the
+// symbol named name, or the empty string for unnamed types like
+// []int and the type descriptors of newer Go releases.
+func typePackage(name string) string {
+	typ, _ := typeName(name)
+	typ = strings.TrimLeft(typ, "*")
+	if typ == "" || !(typ[0] == '_' || unicode.IsLetter(rune(typ[0]))) {
+		return ""
+	}
+	if strings.HasPrefix(typ, "map[") || strings.HasPrefix(typ, "func(") ||
+		strings.HasPrefix(typ, "chan ") || strings.HasPrefix(typ, "struct {") ||
+		strings.HasPrefix(typ, "interface {") {
+		return ""
+	}
+	return varPackage(typ)
+}
+
+// goStrings returns the Go string constant data among syms, which
+// are all of a binary's symbols, as Vars.
+func (f *File) goStrings(syms []Sym) []Var {
+	return f.dataSyms(syms, isStringSym)
+}
+
+// goItabs returns the itabs among syms, which are all of a binary's
+// symbols, as Vars named by itabName.
+func (f *File) goItabs(syms []Sym) []Var {
+	itabs := f.dataSyms(syms, isItabSym)
+	for i := range itabs {
+		itabs[i].Name, _ = itabName(itabs[i].Name)
+	}
+	return itabs
+}
+
+// dataSyms returns the symbols among syms, which are all of a
+// binary's symbols, whose names match, as Vars. A symbol without a
+// size (as in Mach-O, or the go:string.* symbol marking the start of
+// the strings) runs until the next symbol or the end of its data
+// section.
+func (f *File) dataSyms(syms []Sym, match func(name string) bool) []Var {
+	sort.Slice(syms, func(i, j int) bool { return syms[i].Addr < syms[j].Addr })
+	var vars []Var
+	for i, s := range syms {
+		if !match(s.Name) {
+			continue
+		}
+		if s.Size > 0 {
+			vars = append(vars, Var{s.Name, s.Addr, s.Size})
+			continue
+		}
+		for _, sect := range f.DataSections {
+			end := sect.Addr + uint64(sect.Size)
+			if s.Addr < sect.Addr || s.Addr >= end {
+				continue
+			}
+			for _, next := range syms[i+1:] {
+				if next.Addr > s.Addr {
+					end = min(end, next.Addr)
+					break
+				}
+			}
+			vars = append(vars, Var{s.Name, s.Addr, end - s.Addr})
+			break
+		}
+	}
+	return vars
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// debugFilePath returns the path of the separate debug file for the
+// binary bin, open as ra: either the --debug-file flag, or the file
+// named by bin's .gnu_debuglink section, if it can be found. It
+// returns the empty string if there's no debug file. A --debug-file
+// whose CRC doesn't match bin's .gnu_debuglink is warned about, but
+// used anyway.
+func debugFilePath(bin string, ra io.ReaderAt) string {
+	var name string
+	var crc uint32
+	var ok bool
+	if ef, err := elf.NewFile(ra); err == nil {
+		name, crc, ok = parseDebugLink(ef)
+	}
+	if *debugFile != "" {
+		if ok && fileCRC(*debugFile) != crc {
+			warnf("debug file %s doesn't match the CRC in %s's .gnu_debuglink", *debugFile, bin)
+		}
+		return *debugFile
+	}
+	if !ok {
+		return ""
+	}
+	dir := filepath.Dir(bin)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	// The same search path as gdb.
+	for _, path := range []string{
+		filepath.Join(dir, name),
+		filepath.Join(dir, ".debug", name),
+		filepath.Join("/usr/lib/debug", dir, name),
+	} {
+		if path == bin {
+			continue
+		}
+		if fileCRC(path) == crc {
+			if *verbose {
+				log.Printf("using debug file %s from .gnu_debuglink", path)
+			}
+			return path
+		} else if *verbose {
+			log.Printf("no matching debug file at %s", path)
+		}
+	}
+	return ""
+}
+
+// parseDebugLink parses ef's .gnu_debuglink section, which contains
+// a NUL-terminated file name, padding to a 4 byte boundary, and the
+// CRC-32 of the debug file.
+func parseDebugLink(ef *elf.File) (name string, crc uint32, ok bool) {
+	sect := ef.Section(".gnu_debuglink")
+	if sect == nil {
+		return "", 0, false
+	}
+	b, err := sect.Data()
+	if err != nil {
+		return "", 0, false
+	}
+	nul := bytes.IndexByte(b, 0)
+	if nul <= 0 {
+		return "", 0, false
+	}
+	crcOff := (nul + 4) &^ 3
+	if len(b) < crcOff+4 {
+		return "", 0, false
+	}
+	return string(b[:nul]), ef.ByteOrder.Uint32(b[crcOff:]), true
+}
+
+// fileCRC returns the CRC-32 (IEEE) of the file at path, or 0 if it
+// can't be read.
+func fileCRC(path string) uint32 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, f); err != nil {
+		return 0
+	}
+	return h.Sum32()
+}
+
+// withDebugFile fills in what the stripped binary's f (or, if it
+// couldn't be opened, openErr) lacks from the debug file at path.
+// Sizes still come from the stripped binary, of size bytes.
+func withDebugFile(f *File, openErr error, path string, size int64) (*File, error) {
+	df, err := openDebugFile(path)
+	if err != nil {
+		if openErr != nil {
+			return nil, fmt.Errorf("%v; and debug file: %v", openErr, err)
+		}
+		warnf("ignoring debug file: %v", err)
+		return f, nil
+	}
+	if openErr != nil {
+		// The binary itself couldn't be parsed (e.g. its pclntab
+		// couldn't be found), so use the debug file's.
+		if !hasPclntab(df.Gopclntab) {
+			return nil, openErr
+		}
+		df.Size = size
+		return df, nil
+	}
+	if !hasPclntab(f.Gopclntab) && hasPclntab(df.Gopclntab) {
+		f.Gopclntab, f.TextOffset = df.Gopclntab, df.TextOffset
+	}
+	if len(f.TextSyms) == 0 {
+		f.TextSyms = df.TextSyms
+		if df.TextOffset != 0 {
+			// The stripped binary lacked the runtime.text symbol
+			// and had to guess.
+			f.TextOffset = df.TextOffset
+		}
+	}
+	if len(f.Vars) == 0 {
+		f.Vars = df.Vars
+	}
+	if len(f.Strings) == 0 {
+		f.Strings = df.Strings
+	}
+	if len(f.Itabs) == 0 {
+		f.Itabs = df.Itabs
+	}
+	if len(f.Types) == 0 {
+		f.Types = df.Types
+	}
+	return f, nil
+}
+
+func openDebugFile(path string) (*File, error) {
+	dbg, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer dbg.Close()
+	ef, err := elf.NewFile(dbg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f, err := elfFile(ef, 0); err == nil {
+		return f, nil
+	}
+	// Debug files usually have only the symbols, with the
+	// contents of allocated sections like the pclntab omitted.
+	syms, err := ef.Symbols()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	df := &File{TextSyms: elfTextSyms(ef, syms)}
+	for _, sym := range syms {
+		if sym.Name == "runtime.text" {
+			df.TextOffset = sym.Value
+		}
+	}
+	df.addELFData(ef, syms)
+	return df, nil
+}
+
+// hasPclntab reports whether b plausibly begins with a Go 1.2+
+// pclntab header. The pclntab section of a debug file created with
+// objcopy --only-keep-debug has no contents, and reads as zeros.
+func hasPclntab(b []byte) bool {
+	// All the Go 1.2+ magic numbers are 0xfffffffX.
+	return len(b) >= 8 &&
+		(binary.LittleEndian.Uint32(b)>>4 == 0xfffffff || binary.BigEndian.Uint32(b)>>4 == 0xfffffff)
+}
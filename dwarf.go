@@ -0,0 +1,133 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Var is a global variable from a binary's DWARF.
+type Var struct {
+	Name string
+	Addr uint64
+	Size uint64
+}
+
+// dwarfOpAddr is the DWARF location expression opcode for a
+// constant address, which is how Go describes global variables.
+const dwarfOpAddr = 0x03
+
+// dwarfFileVars returns the global variables in the DWARF returned
+// by load, if the --dwarf flag is set. Failure to load the DWARF
+// (e.g. because the binary was linked with -w) is only a warning.
+func dwarfFileVars(load func() (*dwarf.Data, error), order binary.ByteOrder) []Var {
+	if !*dwarfVars {
+		return nil
+	}
+	d, err := load()
+	if err != nil {
+		warnf("no DWARF for --dwarf: %v", err)
+		return nil
+	}
+	vars, err := readDWARFVars(d, order)
+	if err != nil {
+		warnf("reading DWARF variables: %v", err)
+	}
+	return vars
+}
+
+// readDWARFVars returns the global variables in d with a known
+// address and non-zero size, sorted by address.
+func readDWARFVars(d *dwarf.Data, order binary.ByteOrder) ([]Var, error) {
+	var vars []Var
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return vars, err
+		}
+		if e == nil {
+			break
+		}
+		switch e.Tag {
+		case dwarf.TagCompileUnit:
+			// Globals are its children.
+			continue
+		case dwarf.TagVariable:
+		default:
+			// Including the locals of functions.
+			r.SkipChildren()
+			continue
+		}
+		name, _ := e.Val(dwarf.AttrName).(string)
+		loc, _ := e.Val(dwarf.AttrLocation).([]byte)
+		typOff, ok := e.Val(dwarf.AttrType).(dwarf.Offset)
+		if name == "" || !ok || len(loc) != 1+r.AddressSize() || loc[0] != dwarfOpAddr {
+			continue
+		}
+		typ, err := d.Type(typOff)
+		if err != nil || typ.Size() <= 0 {
+			continue
+		}
+		v := Var{Name: name, Size: uint64(typ.Size())}
+		switch r.AddressSize() {
+		case 4:
+			v.Addr = uint64(order.Uint32(loc[1:]))
+		case 8:
+			v.Addr = order.Uint64(loc[1:])
+		default:
+			return vars, errors.New("unsupported DWARF address size")
+		}
+		vars = append(vars, v)
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Addr < vars[j].Addr })
+	return vars, nil
+}
+
+// varPackage returns the package of the global variable named name,
+// or the empty string for linker- and compiler-generated ones.
+func varPackage(name string) string {
+	if strings.HasPrefix(name, "go:") || strings.HasPrefix(name, "type:") ||
+		strings.HasPrefix(name, "go.") || strings.HasPrefix(name, "type.") {
+		return ""
+	}
+	pathend := strings.LastIndex(name, "/")
+	if pathend < 0 {
+		pathend = 0
+	}
+	if i := strings.Index(name[pathend:], "."); i != -1 {
+		return name[:pathend+i]
+	}
+	return ""
+}
+
+// varSizes returns the total size of f's DWARF variables by package
+// and by the name of the data section they're in. Variables outside
+// of f's DataSections are ignored, so as not to count bytes twice.
+func (f *File) varSizes() (byPkg, bySection map[string]int64) {
+	return f.dataSizes(f.Vars)
+}
+
+// dataSizes returns the total size of vars by package and by the
+// name of the data section of f they're in, ignoring those outside
+// of f's DataSections.
+func (f *File) dataSizes(vars []Var) (byPkg, bySection map[string]int64) {
+	byPkg = map[string]int64{}
+	bySection = map[string]int64{}
+	for _, v := range vars {
+		for _, s := range f.DataSections {
+			if v.Addr >= s.Addr && v.Addr+v.Size <= s.Addr+uint64(s.Size) {
+				byPkg[varPackage(v.Name)] += int64(v.Size)
+				bySection[s.Name] += int64(v.Size)
+				break
+			}
+		}
+	}
+	return byPkg, bySection
+}
@@ -0,0 +1,34 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/bradfitz/shotizam/gosym"
+)
+
+// writeFiles writes the files mode's output: a line per source file
+// with its name and the bytes of text and line tables attributed to
+// it, separated by tabs, largest first. The text of t's functions is
+// sized as in f.
+func writeFiles(w io.Writer, f *File, t *gosym.Table) error {
+	sizes, err := t.FileSizes(f.funcTextSize)
+	if err != nil {
+		return err
+	}
+	files := sortedKeys(sizes)
+	sort.SliceStable(files, func(i, j int) bool { return sizes[files[i]] > sizes[files[j]] })
+	for _, file := range files {
+		name := file
+		if name == "" {
+			name = "(unknown)"
+		}
+		fmt.Fprintf(w, "%s\t%d\n", name, sizes[file])
+	}
+	return nil
+}
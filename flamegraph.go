@@ -0,0 +1,30 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+)
+
+//go:embed flamegraph.html
+var flameGraphHTML string
+
+var flameGraphTmpl = template.Must(template.New("flamegraph").Parse(flameGraphHTML))
+
+// flameGraphLevels are the levels of the flamegraph mode's tree.
+const flameGraphLevels = "pkg,type,func"
+
+// writeFlameGraph writes the flamegraph mode's output: a
+// self-contained HTML page drawing the tree rooted at root, titled
+// title, as a zoomable flame graph.
+func writeFlameGraph(w io.Writer, title string, root *TreeNode) error {
+	root.sortBySize()
+	return flameGraphTmpl.Execute(w, struct {
+		Title string
+		Root  *TreeNode
+	}{title, root})
+}
@@ -1,35 +1,114 @@
 package gosym
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
 )
 
 func (t *Table) PtrSize() int { return int(t.go12line.ptrsize) }
 
+// FuncHeaderSize returns the size of the fixed part of the _func
+// struct, before its variable-length pcdata and funcdata arrays. It
+// depends on the target's pointer size before Go 1.18, when the entry
+// PC was a uintptr.
+func (t *Table) FuncHeaderSize() int {
+	lt := t.go12line
+	fd := funcData{t: lt}
+	return int(fd.entrySize()) + int(fd.layout().packed)*4
+}
+
+// Version returns the earliest Go release using t's pclntab format,
+// such as "go1.18", or "unknown".
+func (t *Table) Version() string {
+	if t.go12line == nil {
+		return "go1.1"
+	}
+	return t.go12line.Version()
+}
+
+// Version returns the earliest Go release using t's pclntab format,
+// such as "go1.18". Tables that aren't Go 1.2+ pclntabs are "go1.1".
+func (t *LineTable) Version() string {
+	t.parsePclnTab()
+	if t.version == ver11 {
+		return "go1.1"
+	}
+	if pf := t.format(); pf != nil {
+		return pf.release
+	}
+	return "unknown"
+}
+
+// Validate checks that t has functions, and that they're sorted by
+// entry PC with non-empty, non-overlapping PC ranges. A table failing
+// these checks was probably misparsed.
+func (t *Table) Validate() error {
+	if t.go12line == nil {
+		return errors.New("not a Go 1.2+ pclntab")
+	}
+	if len(t.Funcs) == 0 {
+		return errors.New("no functions found")
+	}
+	for i := range t.Funcs {
+		f := &t.Funcs[i]
+		if f.End <= f.Entry {
+			return fmt.Errorf("function %q has empty PC range [%#x, %#x)", f.Name, f.Entry, f.End)
+		}
+		if i > 0 {
+			if prev := &t.Funcs[i-1]; f.Entry < prev.End {
+				return fmt.Errorf("function %q at %#x overlaps %q ending at %#x", f.Name, f.Entry, prev.Name, prev.End)
+			}
+		}
+	}
+	return nil
+}
+
 func (f *Func) TableSizePCFile() int { return f.tableSize(f.OffPCFile) }
 func (f *Func) TableSizePCSP() int   { return f.tableSize(f.OffPCSP) }
 func (f *Func) TableSizePCLn() int   { return f.tableSize(f.OffPCLn) }
 
 // tab is 0-based table number.
 func (f *Func) TableSizePCData(tab int) int {
+	_, n := f.TableRangePCData(tab)
+	return n
+}
+
+// The TableRange methods return the offset from the start of the
+// pcvalue table and the length in bytes (not counting the terminating
+// zero byte) of one of f's tables, or 0, 0 if f doesn't have it.
+// Functions may share identical tables, which the linker deduplicates.
+func (f *Func) TableRangePCFile() (off, n int) { return f.tableRange(f.OffPCFile) }
+func (f *Func) TableRangePCSP() (off, n int)   { return f.tableRange(f.OffPCSP) }
+func (f *Func) TableRangePCLn() (off, n int)   { return f.tableRange(f.OffPCLn) }
+
+// tab is 0-based table number.
+func (f *Func) TableRangePCData(tab int) (off, n int) {
 	if tab >= f.NumPCData || tab < 0 {
 		log.Fatalf("bogus tab %d; NumPCData=%v", tab, f.NumPCData)
 	}
 	fs := funcData{f.LineTable, f.funcDataBytes}
-	tableOff := fs.tableOff(uint32(tab))
-	if tableOff == 0 {
-		return 0
-	}
-	return f.tableSize(tableOff)
+	return f.tableRange(fs.tableOff(uint32(tab)))
 }
 
 func (f *Func) tableSize(off uint32) int {
+	_, n := f.tableRange(off)
+	return n
+}
+
+func (f *Func) tableRange(off uint32) (int, int) {
+	if off == 0 {
+		return 0, 0
+	}
 	sumSize := 0
 	f.ForeachTableEntry(off, func(val int64, valBytes int, pc uint64, pcBytes int) {
 		sumSize += valBytes + pcBytes
 	})
-	return sumSize
+	return int(off), sumSize
 }
 
 func (f *Func) ForeachTableEntry(off uint32, fn func(val int64, valBytes int, pc uint64, pcBytes int)) {
@@ -41,6 +120,12 @@ func (f *Func) ForeachTableEntry(off uint32, fn func(val int64, valBytes int, pc
 	val := int64(-1)
 
 	for len(data) > 0 && pc < f.End {
+		// A zero value delta (other than the first) terminates
+		// the table. Tables such as the inline tree index may end
+		// before the function does.
+		if data[0] == 0 && pc != f.Entry {
+			break
+		}
 		vald, valBytes := binary.Varint(data)
 		if valBytes <= 0 {
 			panic("bogus")
@@ -61,6 +146,323 @@ func (f *Func) ForeachTableEntry(off uint32, fn func(val int64, valBytes int, pc
 	}
 }
 
+// pcdataNames are the names of the pcdata tables (PCDATA_* in
+// src/internal/abi/symtab.go), by the pclntab version that
+// introduced each list. The tables are their indexes. ArgLiveIndex
+// was added in Go 1.18, and PanicBounds in a later release that
+// still uses the go1.20 format.
+var pcdataNames = []struct {
+	version version
+	names   []string
+}{
+	{ver116, []string{"unsafepoint", "stackmap", "inltree"}},
+	{ver118, []string{"unsafepoint", "stackmap", "inltree", "arglive"}},
+	{ver120, []string{"unsafepoint", "stackmap", "inltree", "arglive", "panicbounds"}},
+}
+
+// PCDataName returns the name of the pcdata table with index n, such
+// as "stackmap", or the empty string if t's version has no such
+// table. The go1.2 pclntab format doesn't say which Go release made
+// it, and its tables' indexes changed across releases, so they're
+// all unknown.
+func (t *LineTable) PCDataName(n int) string {
+	t.parsePclnTab()
+	var names []string
+	for _, l := range pcdataNames {
+		if t.version >= l.version {
+			names = l.names
+		}
+	}
+	if n < 0 || n >= len(names) {
+		return ""
+	}
+	return names[n]
+}
+
+// pcdataInlTreeIndex is the pcdata table mapping PCs to indexes into
+// the function's inline tree. See PCDATA_InlTreeIndex in
+// src/internal/abi/symtab.go.
+const pcdataInlTreeIndex = 2
+
+// NumInlinedCalls returns the number of distinct functions inlined
+// into f, counting those inlined into its inlined calls too. Several
+// calls of the same function count once. It reports false if f has an
+// inline tree that can't be read; see InlineTree.
+func (f *Func) NumInlinedCalls() (n int, ok bool) {
+	tree := f.InlineTree()
+	if tree == nil {
+		return 0, !f.hasInlineTree()
+	}
+	seen := make(map[string]bool)
+	for _, call := range tree {
+		if !seen[call.Name] {
+			seen[call.Name] = true
+			n++
+		}
+	}
+	return n, true
+}
+
+// MaxStack returns the largest offset of the stack pointer from its
+// value at f's entry, according to f's pcsp table: its maximum stack
+// frame size, not counting the return address pushed by its caller on
+// architectures that do so, nor the frames of functions it calls.
+func (f *Func) MaxStack() int {
+	maxSP := 0
+	f.ForeachTableEntry(f.OffPCSP, func(val int64, _ int, _ uint64, _ int) {
+		if int(val) > maxSP {
+			maxSP = int(val)
+		}
+	})
+	return maxSP
+}
+
+// funcdataInlTree is the funcdata index of the inline tree. See
+// FUNCDATA_InlTree in src/internal/abi/symtab.go.
+const funcdataInlTree = 3
+
+// An InlinedCall is an entry of a function's inline tree: a call
+// inlined into the function, or into another of its inlined calls.
+type InlinedCall struct {
+	Name     string // of the inlined function
+	FuncID   uint8
+	ParentPC int32 // offset from the function's entry of a PC in the caller
+}
+
+// hasInlineTree reports whether f has an inline tree, which
+// InlineTree may still be unable to read.
+func (f *Func) hasInlineTree() bool {
+	return f.NumPCData > pcdataInlTreeIndex && f.NumFuncData > funcdataInlTree &&
+		f.FuncDataOffsets[funcdataInlTree] != NoFuncData
+}
+
+// InlineTree returns f's inline tree, indexed by the values of its
+// PCDATA_InlTreeIndex table, which are only ever as large as the
+// tree needs.
+//
+// It returns nil if f has no inlined calls or its inline tree can't
+// be found. The tree is funcdata, which only since Go 1.18 is an
+// offset into the go:func.* data, which the linker puts right after
+// the pclntab (and before runtime.epclntab), so t.Data must include
+// it.
+func (f *Func) InlineTree() (tree []InlinedCall) {
+	t := f.LineTable
+	if t.version < ver118 || !f.hasInlineTree() {
+		return nil
+	}
+	gofunc := t.goFuncOff()
+	if gofunc < 0 {
+		return nil
+	}
+	if !disableRecover {
+		defer func() {
+			if recover() != nil {
+				tree = nil
+			}
+		}()
+	}
+	fd := funcData{t, f.funcDataBytes}
+	n := 0
+	f.ForeachTableEntry(fd.tableOff(pcdataInlTreeIndex), func(val int64, _ int, _ uint64, _ int) {
+		if int(val) >= n {
+			n = int(val) + 1
+		}
+	})
+	b := t.Data[gofunc+int(f.FuncDataOffsets[funcdataInlTree]):]
+
+	// The runtime's inlinedCall struct.
+	size, funcIDAt, nameOffAt, parentPCAt := 16, 0, 4, 8 // Go 1.20+
+	if t.version < ver120 {
+		size, funcIDAt, nameOffAt, parentPCAt = 20, 2, 12, 16
+	}
+	for i := 0; i < n; i++ {
+		e := b[i*size : (i+1)*size]
+		tree = append(tree, InlinedCall{
+			Name:     t.funcName(t.binary.Uint32(e[nameOffAt:])),
+			FuncID:   e[funcIDAt],
+			ParentPC: int32(t.binary.Uint32(e[parentPCAt:])),
+		})
+	}
+	return tree
+}
+
+// InlinedText returns how many bytes of f's text came from the
+// functions inlined into it, keyed by the name of the innermost
+// inlined function. It returns nil if InlineTree does.
+func (f *Func) InlinedText() (sizes map[string]int64) {
+	tree := f.InlineTree()
+	if tree == nil {
+		return nil
+	}
+	fd := funcData{f.LineTable, f.funcDataBytes}
+	start := f.Entry
+	f.ForeachTableEntry(fd.tableOff(pcdataInlTreeIndex), func(val int64, _ int, pc uint64, _ int) {
+		if val >= 0 {
+			if sizes == nil {
+				sizes = map[string]int64{}
+			}
+			sizes[tree[val].Name] += int64(pc - start)
+		}
+		start = pc
+	})
+	return sizes
+}
+
+// FuncDataSizes returns the sizes in bytes of the funcdata f's
+// FuncDataOffsets refer to, or 0 for missing funcdata. Functions
+// may share funcdata, which the linker deduplicates. Each funcdata's
+// size includes any padding after it. It returns nil before Go 1.18
+// or if t.Data doesn't include the funcdata, which follows the
+// pclntab.
+func (f *Func) FuncDataSizes() []int {
+	t := f.LineTable
+	if len(f.FuncDataOffsets) == 0 {
+		return nil
+	}
+	t.funcdataSizesOnce.Do(t.findFuncDataSizes)
+	if t.funcdataSizes == nil {
+		return nil
+	}
+	sizes := make([]int, len(f.FuncDataOffsets))
+	for i, off := range f.FuncDataOffsets {
+		if off != NoFuncData {
+			sizes[i] = t.funcdataSizes[off]
+		}
+	}
+	return sizes
+}
+
+// FuncDataStackObjects is the funcdata index of the stack objects
+// table. See FUNCDATA_StackObjects in src/internal/abi/symtab.go.
+const FuncDataStackObjects = 2
+
+// StackObjectsSize returns the size in bytes of f's table of stack
+// objects, the addressable locals that the garbage collector must
+// scan: a pointer-sized count followed by that many 16 byte
+// stackObjectRecords. It returns 0 if f has none or before Go 1.18.
+func (f *Func) StackObjectsSize() int {
+	t := f.LineTable
+	if len(f.FuncDataOffsets) <= FuncDataStackObjects || f.FuncDataOffsets[FuncDataStackObjects] == NoFuncData {
+		return 0
+	}
+	gofunc := t.goFuncOff()
+	if gofunc < 0 {
+		return 0
+	}
+	p := gofunc + int(f.FuncDataOffsets[FuncDataStackObjects])
+	if p+int(t.ptrsize) > len(t.Data) {
+		return 0
+	}
+	n := t.uintptr(t.Data[p:])
+	size := uint64(t.ptrsize) + 16*n
+	if size > uint64(len(t.Data)-p) {
+		return 0 // corrupt
+	}
+	return int(size)
+}
+
+// findFuncDataSizes sets t.funcdataSizes from the offsets of all the
+// funcdata in go:func.*: each runs until the next. The last runs
+// until the runtime's findfunctab, which follows go:func.* at the end
+// of the pclntab section.
+func (t *LineTable) findFuncDataSizes() {
+	if !disableRecover {
+		defer func() {
+			if recover() != nil {
+				t.funcdataSizes = nil
+			}
+		}()
+	}
+	gofunc := t.goFuncOff()
+	if t.version < ver118 || gofunc < 0 {
+		return
+	}
+	seen := map[uint32]bool{}
+	var offs []uint32
+	for i := uint32(0); i < t.nfunctab; i++ {
+		for _, off := range t.funcData(i).funcDataOffsets() {
+			if off != NoFuncData && !seen[off] {
+				seen[off] = true
+				offs = append(offs, off)
+			}
+		}
+	}
+	sort.Slice(offs, func(i, j int) bool { return offs[i] < offs[j] })
+
+	end := len(t.Data) - gofunc
+	if n := len(offs); n > 0 {
+		if e := end - t.findFuncTabSize(); e > int(offs[n-1]) {
+			end = e
+		}
+	}
+	t.funcdataSizes = map[uint32]int{}
+	for i, off := range offs {
+		next := end
+		if i+1 < len(offs) {
+			next = int(offs[i+1])
+		}
+		t.funcdataSizes[off] = next - int(off)
+	}
+}
+
+// findFuncTabSize returns the size of the runtime's findfunctab for
+// t's text, as computed by the linker's findfunctab: a 4 byte index
+// per 4096 byte bucket of text, and a byte per 256 byte sub-bucket.
+// It's only an estimate, as the linker's text range may differ.
+func (t *LineTable) findFuncTabSize() int {
+	ft := t.funcTab()
+	span := int(ft.pc(ft.Count()) - ft.pc(0))
+	return 4*((span+4095)/4096) + (span+255)/256
+}
+
+// RegionSizes returns the sizes in bytes of the parts of t's pclntab
+// shared by all its functions, by name: "header", "funcnametab",
+// "cutab", "filetab", "pctab", and "functab". Before Go 1.16, the
+// function names and pc-value tables are interleaved with the _func
+// structs, so only the header, functab, and filetab are returned.
+// It returns nil if t isn't a Go 1.2+ pclntab.
+func (t *LineTable) RegionSizes() map[string]int {
+	if !t.isGo12() {
+		return nil
+	}
+	if t.version < ver116 {
+		return map[string]int{
+			// The magic etc., nfunctab, and following the
+			// functab, the filetab offset.
+			"header":  8 + int(t.ptrsize) + 4,
+			"functab": len(t.functab),
+			"filetab": len(t.filetab),
+		}
+	}
+	start := func(b []byte) int { return cap(t.Data) - cap(b) }
+	return map[string]int{
+		"header":      start(t.funcnametab),
+		"funcnametab": len(t.funcnametab),
+		"cutab":       len(t.cutab),
+		"filetab":     len(t.filetab),
+		"pctab":       len(t.pctab),
+		"functab":     len(t.functab),
+	}
+}
+
+// goFuncOff returns the offset in t.Data of the go:func.* funcdata,
+// which follows the pclntab aligned to the pointer size, or -1 if
+// it's not there.
+func (t *LineTable) goFuncOff() int {
+	t.gofuncOnce.Do(func() {
+		t.gofunc = -1
+		size, err := t.DataSize()
+		if err != nil {
+			return
+		}
+		ptr := int(t.ptrsize)
+		if off := (size + ptr - 1) &^ (ptr - 1); off < len(t.Data) {
+			t.gofunc = off
+		}
+	})
+	return t.gofunc
+}
+
 /*
 From src/cmd/link/internal/ld/pcln.go.writeFuncs() and src/runtime/runtime2.go._func:
 
@@ -81,23 +483,634 @@ uint8 nfuncdata;	// number of entries in funcdata list
 
 */
 
-func (f funcData) pcsp() uint32   { return f.field(4) }
-func (f funcData) numPCData() int { return int(f.field(7)) }
+func (f funcData) pcsp() uint32   { return f.field(f.layout().pcsp) }
+func (f funcData) numPCData() int { return int(f.field(f.layout().npcdata)) }
 
 func (f funcData) numFuncData() int {
-	return int(f.field(f.nfuncdataFieldNum()) & 255)
+	if f.layout().nfuncdataWord && !f.t.nfuncdataPacked() {
+		return int(f.field(f.layout().packed))
+	}
+	// The last of the packed bytes: funcID, flag, a pad byte, and
+	// nfuncdata. That's unchanged through at least Go 1.22, as in
+	// runtime/runtime2.go.
+	return int(f.packedByte(3))
+}
+
+// nfuncdataPacked reports whether t's _funcs end in the packed bytes
+// funcID, two pad bytes, and nfuncdata, rather than a word of
+// nfuncdata. The go1.2 format has a word through Go 1.9 and the bytes
+// from Go 1.10 through 1.15, with the same magic number. The pad
+// bytes are always zero, so in a word of packed bytes, the funcID or
+// nfuncdata byte is in the high byte, making the word more than 255,
+// as no function has that many funcdata. A single function can't
+// tell: with a funcID and no funcdata, its word is small either way.
+// But some function of any table with packed bytes has funcdata (or,
+// big-endian, a funcID), so it's decided by the whole table.
+func (t *LineTable) nfuncdataPacked() bool {
+	t.nfuncdataOnce.Do(func() {
+		if !t.format().layout.nfuncdataWord {
+			t.nfuncdataPack = true
+			return
+		}
+		for i := uint32(0); i < t.nfunctab; i++ {
+			fd := t.funcData(i)
+			if fd.field(fd.layout().packed) > 255 {
+				t.nfuncdataPack = true
+				return
+			}
+		}
+	})
+	return t.nfuncdataPack
 }
 
-func (f funcData) nfuncdataFieldNum() uint32 {
-	if f.t.version < ver116 {
-		return 8
+// funcDataArrayOff returns the offset in f.data of the _func's
+// funcdata array, which follows its pcdata table offsets. Before Go
+// 1.18, its entries are pointers, aligned to the pointer size.
+func (f funcData) funcDataArrayOff() int {
+	header := (&Table{go12line: f.t}).FuncHeaderSize()
+	off := header + 4*f.numPCData()
+	if f.t.version < ver118 {
+		ptr := int(f.t.ptrsize)
+		base := len(f.t.Data) - len(f.data)
+		off = (base+off+ptr-1)&^(ptr-1) - base
 	}
-	if f.t.version < ver120 {
-		return 9
+	return off
+}
+
+// funcDataOffsets returns the _func's Go 1.18+ funcdata array.
+func (f funcData) funcDataOffsets() []uint32 {
+	n := f.numFuncData()
+	if n == 0 {
+		return nil
 	}
-	return 10
+	arr := f.data[f.funcDataArrayOff():]
+	offs := make([]uint32, n)
+	for i := range offs {
+		offs[i] = f.t.binary.Uint32(arr[4*i:])
+	}
+	return offs
+}
+
+// FuncDataSize returns the size in bytes of f's funcdata array in the
+// pclntab. The funcdata themselves are elsewhere.
+func (f *Func) FuncDataSize() int {
+	if f.NumFuncData == 0 {
+		return 0
+	}
+	if f.LineTable.version >= ver118 {
+		return 4 * f.NumFuncData
+	}
+	return int(f.LineTable.ptrsize) * f.NumFuncData
+}
+
+// FuncDataPadding returns the number of alignment padding bytes
+// between f's pcdata table offsets and its funcdata array. Before Go
+// 1.18 the funcdata array holds pointers, so on 64-bit systems it
+// needs 4 bytes of padding after an odd number of pcdata tables.
+func (f *Func) FuncDataPadding() int {
+	if f.NumFuncData == 0 || f.LineTable.version >= ver118 {
+		return 0
+	}
+	fd := funcData{f.LineTable, f.funcDataBytes}
+	header := (&Table{go12line: f.LineTable}).FuncHeaderSize()
+	return fd.funcDataArrayOff() - header - 4*f.NumPCData
 }
 
 func (f funcData) tableOff(tab uint32) uint32 {
-	return f.field(f.nfuncdataFieldNum() + 1 + tab)
+	return f.field(f.layout().packed + 1 + tab)
+}
+
+// CUOffset returns the index into the cutab of the first file of f's
+// compilation unit, or ^uint32(0) if f has no compilation unit (as is
+// the case for some linker-generated functions).
+//
+// Go 1.2 through 1.15 binaries don't have compilation units; for
+// those, CUOffset returns 0, corresponding to the single compilation
+// unit returned by Table.CompUnits.
+func (f *Func) CUOffset() uint32 {
+	if f.LineTable.version < ver116 {
+		return 0
+	}
+	return funcData{f.LineTable, f.funcDataBytes}.cuOffset()
+}
+
+// A CompUnit is a compilation unit: the files that were compiled
+// together, typically making up one package.
+type CompUnit struct {
+	// Offset is the index into the cutab of the compilation unit's
+	// first file. It matches Func.CUOffset.
+	Offset uint32
+
+	// Files are the compilation unit's files, indexed by the
+	// values of the functions' pcfile tables. Unused entries
+	// are empty strings.
+	Files []string
+
+	// CutabSize is the number of bytes of cutab used by the
+	// compilation unit.
+	CutabSize int
+
+	// FiletabSize is the number of bytes of file names the
+	// compilation unit references. Names may be shared with
+	// other compilation units.
+	FiletabSize int
+}
+
+// CompUnits returns the compilation units of the program, in cutab
+// order.
+//
+// For Go 1.2 through 1.15 binaries, which have a single file table
+// for the whole program, it returns one CompUnit containing all files.
+func (t *Table) CompUnits() []CompUnit {
+	lt := t.go12line
+	if lt == nil {
+		return nil
+	}
+	if lt.version < ver116 {
+		cu := CompUnit{Files: make([]string, lt.nfiletab)}
+		for i := uint32(1); i < lt.nfiletab; i++ {
+			cu.Files[i] = lt.string(lt.binary.Uint32(lt.filetab[4*i:]))
+			cu.FiletabSize += len(cu.Files[i]) + 1
+		}
+		return []CompUnit{cu}
+	}
+
+	// The cutab doesn't record where each compilation unit ends,
+	// so find all the compilation unit starts via the functions.
+	seen := map[uint32]bool{}
+	var offs []uint32
+	for i := range t.Funcs {
+		off := t.Funcs[i].CUOffset()
+		if off != ^uint32(0) && !seen[off] {
+			seen[off] = true
+			offs = append(offs, off)
+		}
+	}
+	sort.Slice(offs, func(i, j int) bool { return offs[i] < offs[j] })
+
+	ncutab := uint32(len(lt.cutab) / 4)
+	cus := make([]CompUnit, len(offs))
+	for i, off := range offs {
+		end := ncutab
+		if i+1 < len(offs) {
+			end = offs[i+1]
+		}
+		cu := &cus[i]
+		cu.Offset = off
+		cu.CutabSize = int(end-off) * 4
+		for j := off; j < end; j++ {
+			var name string
+			if fnoff := lt.binary.Uint32(lt.cutab[j*4:]); fnoff != ^uint32(0) {
+				name = lt.stringFrom(lt.filetab, fnoff)
+				cu.FiletabSize += len(name) + 1
+			}
+			cu.Files = append(cu.Files, name)
+		}
+	}
+	return cus
+}
+
+// A FileRange is a range of PCs within a function whose code came
+// from a single source file.
+type FileRange struct {
+	Start, End uint64 // [Start, End)
+	File       string
+}
+
+// PCFileEntries returns the ranges of f's PCs by source file, in PC
+// order, as recorded by f's pcfile table. Most functions come from a
+// single file, but inlining can bring in code from others.
+//
+// It returns nil if the table can't be decoded.
+func (f *Func) PCFileEntries() (ranges []FileRange) {
+	t := f.LineTable
+	if !disableRecover {
+		defer func() {
+			if recover() != nil {
+				ranges = nil
+			}
+		}()
+	}
+	if f.OffPCFile == 0 {
+		return nil
+	}
+	fd := funcData{t, f.funcDataBytes}
+	p := t.pctab[f.OffPCFile:]
+	pc, val := f.Entry, int32(-1)
+	start := pc
+	for pc < f.End && t.step(&p, &pc, &val, pc == f.Entry) {
+		// val is in effect from start up to, but not including, pc.
+		end := min(pc, f.End)
+		file := t.fileName(fd, val)
+		if n := len(ranges); n > 0 && ranges[n-1].File == file && ranges[n-1].End == start {
+			ranges[n-1].End = end
+		} else {
+			ranges = append(ranges, FileRange{start, end, file})
+		}
+		start = pc
+	}
+	return ranges
+}
+
+// ForeachLine calls fn for each range of f's PCs [pcStart, pcEnd)
+// from a single source line, in PC order, by walking f's pcln and
+// pcfile tables together. The file is empty for PCs the pcfile table
+// doesn't cover. It stops early if the tables can't be decoded.
+func (t *LineTable) ForeachLine(f *Func, fn func(pcStart, pcEnd uint64, file string, line int)) {
+	if !disableRecover {
+		defer func() {
+			recover()
+		}()
+	}
+	if f.OffPCLn == 0 {
+		return
+	}
+	files := f.PCFileEntries()
+	p := t.pctab[f.OffPCLn:]
+	pc, val := f.Entry, int32(-1)
+	start := pc
+	for pc < f.End && t.step(&p, &pc, &val, pc == f.Entry) {
+		// Line val is in effect from start up to, but not
+		// including, end, perhaps across several files.
+		end := min(pc, f.End)
+		for start < end {
+			for len(files) > 0 && files[0].End <= start {
+				files = files[1:]
+			}
+			file, segEnd := "", end
+			if len(files) > 0 {
+				if files[0].Start <= start {
+					file, segEnd = files[0].File, min(end, files[0].End)
+				} else {
+					segEnd = min(end, files[0].Start)
+				}
+			}
+			fn(start, segEnd, file, int(val))
+			start = segEnd
+		}
+		start = pc
+	}
+}
+
+// FileSizes returns the bytes of t's functions by source file: their
+// text, by their PCFileEntries, and their pcfile and pcln tables.
+// textSize returns the size of a function's text, which may be less
+// than End-Entry, such as when the last function's End is clamped to
+// the end of the text section. The tables and any text not covered by
+// the PCFileEntries (such as padding) go to the file of the
+// function's entry PC, and the bytes of functions with no known file
+// go to the empty string. Tables from NewLazyTable are decoded one
+// function at a time.
+func (t *Table) FileSizes(textSize func(*Func) int64) (map[string]int64, error) {
+	sizes := map[string]int64{}
+	add := func(f *Func) {
+		size := textSize(f)
+		end := f.Entry + uint64(max(size, 0))
+		ranges := f.PCFileEntries()
+		entryFile := ""
+		if len(ranges) > 0 {
+			entryFile = ranges[0].File
+		}
+		var known int64
+		for _, r := range ranges {
+			if r.Start >= end {
+				break
+			}
+			n := int64(min(r.End, end) - r.Start)
+			sizes[r.File] += n
+			known += n
+		}
+		sizes[entryFile] += size - known + int64(f.TableSizePCFile()+f.TableSizePCLn())
+	}
+	if t.lazy {
+		it := t.go12line.FuncIter()
+		for it.Next() {
+			add(it.Func())
+		}
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+	}
+	for i := range t.Funcs {
+		add(&t.Funcs[i])
+	}
+	if sizes[""] == 0 {
+		delete(sizes, "")
+	}
+	return sizes, nil
+}
+
+// ABIWrappers returns the ABI wrappers in t: the compiler-generated
+// functions translating between the ABI0 (stack-based) and
+// ABIInternal (register-based) calling conventions, for calls between
+// Go and assembly.
+//
+// An ABI wrapper has the same name as the function it wraps, but its
+// code is attributed to the "<autogenerated>" file.
+func (t *Table) ABIWrappers() []*Func {
+	count := map[string]int{}
+	for i := range t.Funcs {
+		count[t.Funcs[i].Name]++
+	}
+	var wrappers []*Func
+	for i := range t.Funcs {
+		f := &t.Funcs[i]
+		if count[f.Name] < 2 {
+			continue
+		}
+		if file, _, _ := t.PCToLine(f.Entry); file == "<autogenerated>" {
+			wrappers = append(wrappers, f)
+		}
+	}
+	return wrappers
+}
+
+// A FuncFlag is the flag byte of a function's _func struct, which
+// the runtime's traceback uses. It was added in Go 1.17.
+type FuncFlag uint8
+
+const (
+	// FuncFlagTopFrame is set on functions that are the top of a
+	// call stack, such as runtime.goexit and runtime.mstart.
+	FuncFlagTopFrame FuncFlag = 1 << iota
+	// FuncFlagSPWrite is set on functions that write the stack
+	// pointer other than by adjusting their frame, mostly assembly.
+	FuncFlagSPWrite
+	// FuncFlagAsm is set on functions written in assembly.
+	FuncFlagAsm
+)
+
+// IsTopFrame reports whether f is the top of a call stack.
+func (f *Func) IsTopFrame() bool { return f.Flag&FuncFlagTopFrame != 0 }
+
+// IsSPWrite reports whether f writes the stack pointer arbitrarily.
+func (f *Func) IsSPWrite() bool { return f.Flag&FuncFlagSPWrite != 0 }
+
+// IsAsm reports whether f is written in assembly. It's always false
+// before Go 1.17, which didn't record it.
+func (f *Func) IsAsm() bool { return f.Flag&FuncFlagAsm != 0 }
+
+// RawFunc is the raw contents of a function's _func struct in the
+// pclntab, for debugging. Fields not present in the binary's pclntab
+// version are zero.
+type RawFunc struct {
+	Entry       uint64 // entry PC; or since Go 1.18, its offset from the start of the text
+	NameOff     int32
+	Args        int32
+	DeferReturn uint32
+	PCSP        uint32
+	PCFile      uint32
+	PCLn        uint32
+	NPCData     uint32
+	CUOffset    uint32 // Go 1.16+
+	StartLine   int32  // Go 1.20+
+	FuncID      uint8
+	Flag        uint8 // Go 1.17+
+	NFuncData   uint8
+}
+
+// Raw returns f's raw _func struct.
+func (f *Func) Raw() RawFunc {
+	fd := funcData{f.LineTable, f.funcDataBytes}
+	l := fd.layout()
+	r := RawFunc{
+		NameOff:     int32(fd.nameOff()),
+		Args:        fd.args(),
+		DeferReturn: fd.deferreturn(),
+		PCSP:        fd.pcsp(),
+		PCFile:      fd.pcfile(),
+		PCLn:        fd.pcln(),
+		NPCData:     uint32(fd.numPCData()),
+		CUOffset:    fd.cuOffset(),
+		StartLine:   fd.startLine(),
+		FuncID:      fd.packedByte(0),
+		NFuncData:   fd.packedByte(3),
+	}
+	if !f.LineTable.nfuncdataPacked() {
+		r.FuncID, r.NFuncData = 0, uint8(fd.numFuncData())
+	}
+	if l.hasFlag {
+		r.Flag = fd.packedByte(1)
+	}
+	if l.entryOff {
+		r.Entry = uint64(f.LineTable.binary.Uint32(fd.data))
+	} else {
+		r.Entry = f.LineTable.uintptr(fd.data)
+	}
+	return r
+}
+
+// packedByte returns byte i of the _func's final word, which packs
+// the one byte funcID, flag (Go 1.17+), padding, and nfuncdata fields,
+// in memory order.
+func (f funcData) packedByte(i uint32) uint8 {
+	return f.data[f.entrySize()+(f.layout().packed-1)*4+i]
+}
+
+// FuncNameStats returns statistics about the function name table:
+// its total size in bytes, the bytes of its unique names, and the
+// bytes of names that are a prefix of another name (and so could in
+// theory share its storage). Sizes include NUL terminators.
+func (t *Table) FuncNameStats() (total, unique, prefixShared int64) {
+	lt := t.go12line
+	if lt == nil {
+		return 0, 0, 0
+	}
+	var names []string
+	if lt.version >= ver116 {
+		// The funcnametab is its own region (also holding the
+		// names of inlined functions), so walk it all.
+		total = int64(len(lt.funcnametab))
+		for b := lt.funcnametab; len(b) > 0; {
+			i := bytes.IndexByte(b, 0)
+			if i < 0 {
+				break
+			}
+			names = append(names, string(b[:i]))
+			b = b[i+1:]
+		}
+	} else {
+		// Before Go 1.16, names are interspersed with other data,
+		// so find them via the functions.
+		seen := map[uint32]bool{}
+		for i := uint32(0); i < lt.nfunctab; i++ {
+			off := lt.funcData(i).nameOff()
+			if seen[off] {
+				continue
+			}
+			seen[off] = true
+			name := lt.funcName(off)
+			total += int64(len(name) + 1)
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+		unique += int64(len(name) + 1)
+		if i+1 < len(names) && names[i+1] != name && strings.HasPrefix(names[i+1], name) {
+			prefixShared += int64(len(name) + 1)
+		}
+	}
+	return total, unique, prefixShared
+}
+
+// NewLazyTable returns a Table for the Go 1.2+ pclntab pcln without
+// decoding all its functions up front as NewTable does, for binaries
+// too large for that. Its Funcs, Syms, Files, and Objs are empty;
+// use pcln.FuncIter to visit the functions. PCToFunc still works,
+// decoding a new Func on each call.
+func NewLazyTable(pcln *LineTable) (*Table, error) {
+	if !pcln.isGo12() {
+		return nil, errors.New("not a Go 1.2+ pclntab")
+	}
+	return &Table{go12line: pcln, lazy: true}, nil
+}
+
+// A FuncIter iterates over the functions of a Go 1.2+ pclntab in
+// address order, decoding each only when it's reached.
+type FuncIter struct {
+	t   *LineTable
+	ft  funcTab
+	i   int
+	f   *Func
+	err error
+}
+
+// FuncIter returns an iterator over t's functions. The Funcs it
+// returns are independent of any Table's.
+func (t *LineTable) FuncIter() *FuncIter {
+	it := &FuncIter{t: t, i: -1}
+	if !t.isGo12() {
+		it.err = errors.New("not a Go 1.2+ pclntab")
+		return it
+	}
+	it.ft = t.funcTab()
+	return it
+}
+
+// Next advances to the next function, reporting whether there is
+// one. After it returns false, Err reports any decoding error.
+func (it *FuncIter) Next() (ok bool) {
+	if it.err != nil || it.i+1 >= it.ft.Count() {
+		return false
+	}
+	it.i++
+	if !disableRecover {
+		defer func() {
+			if e := recover(); e != nil {
+				it.f = nil
+				it.err = fmt.Errorf("malformed pclntab at function %d: %v", it.i, e)
+				ok = false
+			}
+		}()
+	}
+	f := new(Func)
+	info := it.t.go12Func(it.ft, it.i, f, new(Sym))
+	// Don't use the funcName cache, which would hold on to all
+	// the names.
+	name := it.t.funcnametab[info.nameOff():]
+	f.Name = string(name[:bytes.IndexByte(name, 0)])
+	it.f = f
+	return true
+}
+
+// Func returns the current function.
+func (it *FuncIter) Func() *Func { return it.f }
+
+// Err returns the error, if any, that stopped the iteration.
+func (it *FuncIter) Err() error { return it.err }
+
+// go12PCToFunc returns a newly decoded Func containing pc, or nil if
+// there is none.
+func (t *LineTable) go12PCToFunc(pc uint64) (f *Func) {
+	if !disableRecover {
+		defer func() {
+			if recover() != nil {
+				f = nil
+			}
+		}()
+	}
+	ft := t.funcTab()
+	if ft.Count() == 0 || pc < ft.pc(0) || pc >= ft.pc(ft.Count()) {
+		return nil
+	}
+	i := sort.Search(ft.Count(), func(i int) bool { return ft.pc(i) > pc }) - 1
+	return t.go12FuncAt(ft, i)
+}
+
+// go12FuncAt returns a newly decoded Func of the ith function of ft.
+func (t *LineTable) go12FuncAt(ft funcTab, i int) *Func {
+	f := new(Func)
+	info := t.go12Func(ft, i, f, new(Sym))
+	f.Name = t.funcName(info.nameOff())
+	return f
+}
+
+// EndFuncs returns t's first and last functions, or nils if it has
+// none. Unlike Funcs, it works on Tables from NewLazyTable too,
+// decoding just those two.
+func (t *Table) EndFuncs() (first, last *Func) {
+	if !t.lazy {
+		if len(t.Funcs) == 0 {
+			return nil, nil
+		}
+		return &t.Funcs[0], &t.Funcs[len(t.Funcs)-1]
+	}
+	if !disableRecover {
+		defer func() {
+			if recover() != nil {
+				first, last = nil, nil
+			}
+		}()
+	}
+	ft := t.go12line.funcTab()
+	if ft.Count() == 0 {
+		return nil, nil
+	}
+	return t.go12line.go12FuncAt(ft, 0), t.go12line.go12FuncAt(ft, ft.Count()-1)
+}
+
+// DataSize returns the size of t's pclntab, for finding its end in a
+// binary without a runtime.epclntab symbol to mark it. Since Go 1.16
+// the _func structs are last, so it's the end of the last of them.
+// (The linker's runtime.pclntab to runtime.epclntab range can be
+// bigger, including the funcdata and the runtime's findfunctab.)
+// For older pclntabs, it's len(t.Data).
+func (t *LineTable) DataSize() (size int, err error) {
+	if !disableRecover {
+		defer func() {
+			if e := recover(); e != nil {
+				size, err = 0, fmt.Errorf("malformed pclntab: %v", e)
+			}
+		}()
+	}
+	if !t.isGo12() {
+		return 0, errors.New("not a Go 1.2+ pclntab")
+	}
+	if t.version < ver116 {
+		return len(t.Data), nil
+	}
+	funcHeader := (&Table{go12line: t}).FuncHeaderSize()
+	for i := 0; i < int(t.nfunctab); i++ {
+		f := t.funcData(uint32(i))
+		n := len(t.Data) - len(f.data) + funcHeader + 4*f.numPCData()
+		if nfd := f.numFuncData(); nfd > 0 {
+			n = len(t.Data) - len(f.data) + f.funcDataArrayOff()
+			if t.version >= ver118 {
+				// Offsets from go:func.*.
+				n += 4 * nfd
+			} else {
+				n += int(t.ptrsize) * nfd
+			}
+		}
+		size = max(size, n)
+	}
+	if size > len(t.Data) {
+		return 0, errors.New("malformed pclntab: _func past the end")
+	}
+	return size, nil
 }
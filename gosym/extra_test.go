@@ -0,0 +1,1060 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gosym
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testProg = `package main
+
+import "fmt"
+
+type T struct{ n int }
+
+//go:noinline
+func (t *T) Inc() { t.n++ }
+
+func double(x int) int { return x * 2 }
+
+//go:noinline
+func deferred() {
+	defer fmt.Println("done")
+	fmt.Println("hi")
+}
+
+func main() {
+	t := new(T)
+	t.Inc()
+	fmt.Println(double(t.n), double(t.n+1))
+	deferred()
+}
+`
+
+var (
+	buildOnce sync.Once
+	buildDir  string
+	buildErr  error
+)
+
+// buildTestBinary builds testProg for goos/goarch with the local Go
+// toolchain and returns the binary's path, skipping the test if
+// that's not possible.
+func buildTestBinary(t *testing.T, goos, goarch string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping binary build in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	buildOnce.Do(func() {
+		buildDir, buildErr = os.MkdirTemp("", "gosym-test")
+		if buildErr != nil {
+			return
+		}
+		buildErr = os.WriteFile(filepath.Join(buildDir, "main.go"), []byte(testProg), 0644)
+	})
+	if buildErr != nil {
+		t.Fatal(buildErr)
+	}
+	bin := filepath.Join(buildDir, "prog."+goos+"-"+goarch)
+	if _, err := os.Stat(bin); err == nil {
+		return bin
+	}
+	cmd := exec.Command(goTool, "build", "-o", bin, "main.go")
+	cmd.Dir = buildDir
+	cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH="+goarch, "CGO_ENABLED=0", "GO111MODULE=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building test binary: %v\n%s", err, out)
+	}
+	return bin
+}
+
+// testTable returns the Table of a test binary built for linux/goarch.
+func testTable(t *testing.T, goarch string) *Table {
+	t.Helper()
+	ef, err := elf.Open(buildTestBinary(t, "linux", goarch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ef.Close()
+	data, err := ef.Section(".gopclntab").Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tab, err := NewTable(nil, NewLineTable(data, ef.Section(".text").Addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tab
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if buildDir != "" {
+		os.RemoveAll(buildDir)
+	}
+	os.Exit(code)
+}
+
+func TestValidate(t *testing.T) {
+	tab := testTable(t, "amd64")
+	if err := tab.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompUnits(t *testing.T) {
+	tab := testTable(t, "amd64")
+	cus := tab.CompUnits()
+	ncutab := 0
+	for _, cu := range cus {
+		ncutab += cu.CutabSize / 4
+	}
+	f := tab.LookupFunc("main.(*T).Inc")
+	if f == nil {
+		t.Fatal("main.(*T).Inc not found")
+	}
+	off := f.CUOffset()
+	if int(off) >= ncutab {
+		t.Fatalf("CUOffset = %d; want under the %d cutab entries of %d CompUnits", off, ncutab, len(cus))
+	}
+	var cu *CompUnit
+	for i := range cus {
+		if cus[i].Offset == off {
+			cu = &cus[i]
+		}
+	}
+	if cu == nil {
+		t.Fatalf("no CompUnit at CUOffset %d", off)
+	}
+	for _, r := range f.PCFileEntries() {
+		if !slices.Contains(cu.Files, r.File) {
+			t.Errorf("CompUnit files %q lack %q, from PCFileEntries", cu.Files, r.File)
+		}
+	}
+}
+
+func TestPCFileEntries(t *testing.T) {
+	tab := testTable(t, "amd64")
+	f := tab.LookupFunc("main.(*T).Inc")
+	if f == nil {
+		t.Fatal("main.(*T).Inc not found")
+	}
+	ranges := f.PCFileEntries()
+	if len(ranges) != 1 || ranges[0].Start != f.Entry || filepath.Base(ranges[0].File) != "main.go" {
+		t.Errorf("PCFileEntries of main.(*T).Inc = %+v; want one range in main.go starting at %#x", ranges, f.Entry)
+	}
+	for i := range tab.Funcs {
+		f := &tab.Funcs[i]
+		for j, r := range f.PCFileEntries() {
+			if r.Start >= r.End || r.Start < f.Entry || r.End > f.End {
+				t.Fatalf("%s: range %d = %+v outside [%#x, %#x)", f.Name, j, r, f.Entry, f.End)
+			}
+		}
+	}
+}
+
+func TestABIWrappers(t *testing.T) {
+	tab := testTable(t, "amd64")
+	wrappers := tab.ABIWrappers()
+	if len(wrappers) == 0 {
+		t.Fatal("no ABI wrappers found")
+	}
+	for _, f := range wrappers {
+		// Every wrapper wraps a function of the same name.
+		var n int
+		for i := range tab.Funcs {
+			if tab.Funcs[i].Name == f.Name {
+				n++
+			}
+		}
+		if n < 2 {
+			t.Errorf("wrapper %q doesn't wrap anything", f.Name)
+		}
+	}
+}
+
+func TestNumInlinedCalls(t *testing.T) {
+	tab := testTable(t, "amd64")
+	f := tab.LookupFunc("main.main")
+	tree := f.InlineTree()
+	doubles := 0
+	for _, call := range tree {
+		if call.Name == "main.double" {
+			doubles++
+		}
+	}
+	if doubles != 2 {
+		t.Errorf("main.main's inline tree %v has %d calls of main.double; want 2", tree, doubles)
+	}
+	// Both calls of double count once.
+	if n, ok := f.NumInlinedCalls(); !ok || n < 1 || n >= len(tree) {
+		t.Errorf("main.main NumInlinedCalls = %d, %v; want 1 to %d, true", n, ok, len(tree)-1)
+	}
+	if n, ok := tab.LookupFunc("main.(*T).Inc").NumInlinedCalls(); n != 0 || !ok {
+		t.Errorf("main.(*T).Inc NumInlinedCalls = %d, %v; want 0, true", n, ok)
+	}
+}
+
+func TestFuncIter(t *testing.T) {
+	tab := testTable(t, "amd64")
+	it := tab.go12line.FuncIter()
+	n := 0
+	for it.Next() {
+		got, want := it.Func(), &tab.Funcs[n]
+		if got.Name != want.Name || got.Entry != want.Entry || got.End != want.End || got.OffPCLn != want.OffPCLn {
+			t.Fatalf("func %d = %q [%#x, %#x); want %q [%#x, %#x)", n, got.Name, got.Entry, got.End, want.Name, want.Entry, want.End)
+		}
+		n++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(tab.Funcs) {
+		t.Errorf("iterated over %d funcs; want %d", n, len(tab.Funcs))
+	}
+
+	lazy, err := NewLazyTable(tab.go12line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := tab.LookupFunc("main.main")
+	if got := lazy.PCToFunc(want.Entry + 1); got == nil || got.Name != want.Name {
+		t.Errorf("lazy PCToFunc = %v; want %q", got, want.Name)
+	}
+	first, last := lazy.EndFuncs()
+	wantFirst, wantLast := tab.EndFuncs()
+	if first == nil || first.Name != wantFirst.Name || first.Entry != wantFirst.Entry ||
+		last == nil || last.Name != wantLast.Name || last.End != wantLast.End {
+		t.Errorf("lazy EndFuncs = %v, %v; want %v, %v", first, last, wantFirst, wantLast)
+	}
+}
+
+// TestForeignTargets checks that binaries whose byte order or pointer
+// size differ from the host's are parsed correctly.
+func TestForeignTargets(t *testing.T) {
+	for _, tt := range []struct {
+		goarch  string
+		ptrSize int
+	}{
+		{"386", 4},   // little endian, 32-bit
+		{"mips", 4},  // big endian, 32-bit
+		{"s390x", 8}, // big endian, 64-bit
+	} {
+		t.Run(tt.goarch, func(t *testing.T) {
+			tab := testTable(t, tt.goarch)
+			if err := tab.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if got := tab.PtrSize(); got != tt.ptrSize {
+				t.Errorf("PtrSize = %d; want %d", got, tt.ptrSize)
+			}
+			f := tab.LookupFunc("main.(*T).Inc")
+			if f == nil {
+				t.Fatal("main.(*T).Inc not found")
+			}
+			if file, _, _ := tab.PCToLine(f.Entry); filepath.Base(file) != "main.go" {
+				t.Errorf("file of main.(*T).Inc = %q; want main.go", file)
+			}
+			var tableBytes int
+			for i := range tab.Funcs {
+				f := &tab.Funcs[i]
+				tableBytes += f.TableSizePCSP() + f.TableSizePCFile() + f.TableSizePCLn()
+				for j := 0; j < f.NumPCData; j++ {
+					tableBytes += f.TableSizePCData(j)
+				}
+			}
+			// The linker deduplicates identical tables, so the
+			// total may exceed the pctab size, but not by much.
+			if tableBytes == 0 || tableBytes > 2*len(tab.go12line.pctab) {
+				t.Errorf("total table size %d; want between 0 and twice the pctab size %d", tableBytes, len(tab.go12line.pctab))
+			}
+		})
+	}
+}
+
+func TestFuncNameStats(t *testing.T) {
+	tab := testTable(t, "amd64")
+	total, unique, prefixShared := tab.FuncNameStats()
+	if !(total >= unique && unique > prefixShared && prefixShared > 0) {
+		t.Errorf("FuncNameStats = %d, %d, %d; want total >= unique > prefixShared > 0", total, unique, prefixShared)
+	}
+	var funcNames int64
+	for i := range tab.Funcs {
+		funcNames += int64(len(tab.Funcs[i].Name) + 1)
+	}
+	if total < funcNames/2 {
+		t.Errorf("total = %d; implausibly small for %d bytes of function names", total, funcNames)
+	}
+}
+
+func TestDataSize(t *testing.T) {
+	ef, err := elf.Open(buildTestBinary(t, "linux", "amd64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ef.Close()
+	sect := ef.Section(".gopclntab")
+	data, err := sect.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := NewLineTable(data, ef.Section(".text").Addr).DataSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The section also has the funcdata, which follows the
+	// pclntab, perhaps after padding.
+	syms, err := ef.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range syms {
+		if s.Name == "go:func.*" {
+			if off := int(s.Value - sect.Addr); size > off || off-size >= 8 {
+				t.Errorf("DataSize = %d; want the funcdata's offset, %d", size, off)
+			}
+			return
+		}
+	}
+	t.Fatal("no go:func.* symbol")
+}
+
+// handBuiltTable returns a minimal hand-built Go 1.18+ pclntab with
+// the given magic number and two functions, main.f at
+// [text, text+0x10) and main.g at [text+0x10, text+0x30). main.f
+// shares a 2 byte pc-value table between its pcsp and only pcdata
+// table. Their _func structs have funcFields uint32 fields before the
+// 4 packed bytes ending in nfuncdata, and main.f's is followed by its
+// pcdata table offset.
+func handBuiltTable(magic uint32, funcFields int) []byte {
+	le := binary.LittleEndian
+	const (
+		funcnameOff = 72               // after the 8 byte header and 8 words
+		cuOff       = funcnameOff + 14 // "main.f\x00main.g\x00"
+		filetabOff  = cuOff + 4
+		pctabOff    = filetabOff + 8 // "main.go\x00"
+		pclnOff     = 104            // aligned
+		functabSize = (2*2 + 1) * 4
+	)
+	funcSize := funcFields*4 + 4
+	b := make([]byte, pclnOff+functabSize+2*funcSize+4)
+	le.PutUint32(b, magic)
+	b[6], b[7] = 1, 8 // quantum, ptrsize
+	for i, v := range []uint64{2, 1, 0xdead0000 /* unrelocated textStart */, funcnameOff, cuOff, filetabOff, pctabOff, pclnOff} {
+		le.PutUint64(b[8+8*i:], v)
+	}
+	copy(b[funcnameOff:], "main.f\x00main.g\x00")
+	copy(b[filetabOff:], "main.go\x00")
+	copy(b[pctabOff+1:], "\x02\x10\x00") // value 0 for 0x10 bytes; offset 0 is "no table"
+	ft := b[pclnOff:]
+	for i, v := range []uint32{0, functabSize, 0x10, uint32(functabSize + funcSize + 4), 0x30} {
+		le.PutUint32(ft[4*i:], v)
+	}
+	for i, f := range []struct{ entryOff, nameOff, pctab uint32 }{{0, 0, 1}, {0x10, 7, 0}} {
+		fn := ft[functabSize+i*(funcSize+4):]
+		le.PutUint32(fn[0:], f.entryOff)
+		le.PutUint32(fn[4:], f.nameOff)
+		if f.pctab != 0 {
+			le.PutUint32(fn[16:], f.pctab)       // pcsp
+			le.PutUint32(fn[28:], 1)             // npcdata
+			le.PutUint32(fn[funcSize:], f.pctab) // pcdata[0]
+		}
+	}
+	return b
+}
+
+func TestHandBuiltTables(t *testing.T) {
+	const text = 0x401000
+	for _, pf := range pclntabFormats {
+		if pf.version < ver118 {
+			// handBuiltTable only makes Go 1.18+ headers.
+			continue
+		}
+		funcFields := int(pf.layout.packed) // including the entry, before the packed bytes
+		t.Run(pf.release, func(t *testing.T) {
+			lt := NewLineTable(handBuiltTable(pf.magic, funcFields), text)
+			tab, err := NewTable(nil, lt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tab.Version(); got != pf.release {
+				t.Errorf("Version = %q; want %q", got, pf.release)
+			}
+			if got := lt.Version(); got != pf.release {
+				t.Errorf("LineTable.Version = %q; want %q", got, pf.release)
+			}
+			if err := tab.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []struct {
+				name       string
+				entry, end uint64
+			}{
+				{"main.f", text, text + 0x10},
+				{"main.g", text + 0x10, text + 0x30},
+			} {
+				f := tab.LookupFunc(want.name)
+				if f == nil {
+					t.Errorf("%s not found", want.name)
+					continue
+				}
+				if f.Entry != want.entry || f.End != want.end {
+					t.Errorf("%s = [%#x, %#x); want [%#x, %#x)", want.name, f.Entry, f.End, want.entry, want.end)
+				}
+			}
+			if got, want := tab.FuncHeaderSize(), funcFields*4+4; got != want {
+				t.Errorf("FuncHeaderSize = %d; want %d", got, want)
+			}
+			if size, err := lt.DataSize(); err != nil || size != len(lt.Data) {
+				t.Errorf("DataSize = %d, %v; want %d", size, err, len(lt.Data))
+			}
+			f := tab.LookupFunc("main.f")
+			if f.NumPCData != 1 || f.TableSizePCSP() != 2 || f.TableSizePCData(0) != 2 {
+				t.Errorf("main.f: NumPCData = %d, TableSizePCSP = %d, TableSizePCData(0) = %d; want 1, 2, 2",
+					f.NumPCData, f.TableSizePCSP(), f.TableSizePCData(0))
+			}
+			if g := tab.LookupFunc("main.g"); g.NumPCData != 0 || g.TableSizePCSP() != 0 {
+				t.Errorf("main.g: NumPCData = %d, TableSizePCSP = %d; want 0, 0", g.NumPCData, g.TableSizePCSP())
+			}
+		})
+	}
+}
+
+// handBuiltGo12Table returns a little-endian go1.2 format pclntab of
+// main.f, with two funcdata, and main.g, with a funcID and none. Both
+// have 0x28 in the word that's their frame size in Go 1.2 and their
+// deferreturn offset in later versions. If
+// packed, their _func structs end in the funcID, pad, pad, and
+// nfuncdata bytes of Go 1.10 through 1.15, else in a word of
+// nfuncdata, as through Go 1.9.
+func handBuiltGo12Table(packed bool) []byte {
+	le := binary.LittleEndian
+	const (
+		functabOff = 16                   // after the header and nfunctab
+		filetabOff = functabOff + 5*8 + 4 // after the functab and filetab offset
+		nameOff    = filetabOff + 4       // after a filetab with just its length
+		funcOff    = 80                   // after "main.f\x00main.g\x00", aligned
+		funcSize   = 8 + 8*4              // the entry PC and 8 fields
+	)
+	funcs := []struct {
+		nameOff           uint32
+		funcID, nfuncdata byte
+	}{{nameOff, 0, 2}, {nameOff + 7, 5, 0}}
+	b := make([]byte, funcOff+2*funcSize+2*8)
+	le.PutUint32(b, go12magic)
+	b[6], b[7] = 1, 8 // quantum, ptrsize
+	le.PutUint64(b[8:], uint64(len(funcs)))
+	off := funcOff
+	for i, f := range funcs {
+		le.PutUint64(b[functabOff+16*i:], 0x1000+0x10*uint64(i))
+		le.PutUint64(b[functabOff+16*i+8:], uint64(off))
+		fn := b[off:]
+		le.PutUint64(fn, 0x1000+0x10*uint64(i))
+		le.PutUint32(fn[8:], f.nameOff)
+		le.PutUint32(fn[16:], 0x28) // the frame size or deferreturn offset
+		if packed {
+			fn[36], fn[39] = f.funcID, f.nfuncdata
+		} else {
+			le.PutUint32(fn[36:], uint32(f.nfuncdata))
+		}
+		off += funcSize + 8*int(f.nfuncdata) // and its funcdata pointers
+	}
+	le.PutUint64(b[functabOff+16*len(funcs):], 0x1020) // end PC
+	le.PutUint32(b[functabOff+16*len(funcs)+8:], filetabOff)
+	le.PutUint32(b[filetabOff:], 1)
+	copy(b[nameOff:], "main.f\x00main.g\x00")
+	return b
+}
+
+func TestNumFuncDataGo12(t *testing.T) {
+	for _, packed := range []bool{false, true} {
+		lt := NewLineTable(handBuiltGo12Table(packed), 0x1000)
+		tab, err := NewTable(nil, lt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tab.Version(); got != "go1.2" {
+			t.Fatalf("Version = %q; want go1.2", got)
+		}
+		for _, want := range []struct {
+			name      string
+			nfuncdata int
+			funcID    uint8
+		}{{"main.f", 2, 0}, {"main.g", 0, 5}} {
+			f := tab.LookupFunc(want.name)
+			if f == nil {
+				t.Fatalf("packed=%v: %s not found", packed, want.name)
+			}
+			if f.NumFuncData != want.nfuncdata {
+				t.Errorf("packed=%v: %s NumFuncData = %d; want %d", packed, want.name, f.NumFuncData, want.nfuncdata)
+			}
+			if got := f.FuncDataSize(); got != 8*want.nfuncdata {
+				t.Errorf("packed=%v: %s FuncDataSize = %d; want %d", packed, want.name, got, 8*want.nfuncdata)
+			}
+			if !packed {
+				want.funcID = 0 // a word of nfuncdata has no funcID
+			}
+			if raw := f.Raw(); int(raw.NFuncData) != want.nfuncdata || raw.FuncID != want.funcID {
+				t.Errorf("packed=%v: %s Raw NFuncData, FuncID = %d, %d; want %d, %d",
+					packed, want.name, raw.NFuncData, raw.FuncID, want.nfuncdata, want.funcID)
+			}
+		}
+	}
+}
+
+func TestNumFuncData(t *testing.T) {
+	for _, goarch := range []string{"amd64", "s390x"} {
+		tab := testTable(t, goarch)
+		for i := range tab.Funcs {
+			f := &tab.Funcs[i]
+			if raw := f.Raw(); f.NumFuncData != int(raw.NFuncData) {
+				t.Fatalf("%s: %s: NumFuncData = %d; want %d", goarch, f.Name, f.NumFuncData, raw.NFuncData)
+			}
+		}
+		// main.main calls a function, so has at least its args and
+		// locals pointer maps.
+		f := tab.LookupFunc("main.main")
+		if f == nil {
+			t.Fatalf("%s: main.main not found", goarch)
+		}
+		if f.NumFuncData < 2 {
+			t.Errorf("%s: main.main has %d funcdata; want at least 2", goarch, f.NumFuncData)
+		}
+	}
+}
+
+func TestStartLine(t *testing.T) {
+	tab := testTable(t, "amd64")
+	f := tab.LookupFunc("main.main")
+	if f == nil {
+		t.Fatal("main.main not found")
+	}
+	want := strings.Count(testProg[:strings.Index(testProg, "func main()")], "\n") + 1
+	if f.StartLine != want {
+		t.Errorf("StartLine = %d; want %d", f.StartLine, want)
+	}
+	if raw := f.Raw(); int(raw.StartLine) != f.StartLine {
+		t.Errorf("Raw().StartLine = %d; want %d", raw.StartLine, f.StartLine)
+	}
+}
+
+func TestInlinedText(t *testing.T) {
+	for _, goarch := range []string{"amd64", "s390x"} {
+		tab := testTable(t, goarch)
+		f := tab.LookupFunc("main.main")
+		if f == nil {
+			t.Fatalf("%s: main.main not found", goarch)
+		}
+		inl := f.InlinedText()
+		if inl["main.double"] <= 0 {
+			t.Errorf("%s: InlinedText = %v; want some main.double", goarch, inl)
+		}
+		var sum int64
+		for _, n := range inl {
+			sum += n
+		}
+		if sum > int64(f.End-f.Entry) {
+			t.Errorf("%s: %d inlined bytes in a %d byte function", goarch, sum, f.End-f.Entry)
+		}
+	}
+}
+
+func TestDeferReturn(t *testing.T) {
+	tab := testTable(t, "amd64")
+	f := tab.LookupFunc("main.deferred")
+	if f == nil {
+		t.Fatal("main.deferred not found")
+	}
+	if f.DeferReturn == 0 || uint64(f.DeferReturn) >= f.End-f.Entry {
+		t.Errorf("main.deferred DeferReturn = %d; want an offset within its %d bytes", f.DeferReturn, f.End-f.Entry)
+	}
+	if f := tab.LookupFunc("main.(*T).Inc"); f.DeferReturn != 0 {
+		t.Errorf("main.(*T).Inc DeferReturn = %d; want 0", f.DeferReturn)
+	}
+}
+
+// TestDeferReturnGo12 tests that the go1.2 format's deferreturn word,
+// which was the frame size in Go 1.2, isn't reported as DeferReturn.
+func TestDeferReturnGo12(t *testing.T) {
+	tab, err := NewTable(nil, NewLineTable(handBuiltGo12Table(true), 0x1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := tab.LookupFunc("main.f")
+	if f == nil {
+		t.Fatal("main.f not found")
+	}
+	if f.DeferReturn != 0 {
+		t.Errorf("DeferReturn = %#x; want 0", f.DeferReturn)
+	}
+	if raw := f.Raw(); raw.DeferReturn != 0x28 {
+		t.Errorf("Raw DeferReturn = %#x; want 0x28", raw.DeferReturn)
+	}
+}
+
+func TestFuncFlag(t *testing.T) {
+	tab := testTable(t, "amd64")
+	for _, tt := range []struct {
+		name          string
+		asm, topFrame bool
+	}{
+		{"main.main", false, false},
+		{"runtime.memmove", true, false},
+		{"runtime.goexit", true, true},
+		{"runtime.mstart", true, true},
+	} {
+		f := tab.LookupFunc(tt.name)
+		if f == nil {
+			t.Errorf("%s not found", tt.name)
+			continue
+		}
+		if f.IsAsm() != tt.asm || f.IsTopFrame() != tt.topFrame {
+			t.Errorf("%s: IsAsm = %v, IsTopFrame = %v; want %v, %v", tt.name, f.IsAsm(), f.IsTopFrame(), tt.asm, tt.topFrame)
+		}
+	}
+}
+
+func TestFuncDataOffsets(t *testing.T) {
+	tab := testTable(t, "amd64")
+	lt := tab.go12line
+	gofunc := lt.goFuncOff()
+	if gofunc < 0 {
+		t.Fatal("no go:func.*")
+	}
+	for i := range tab.Funcs {
+		f := &tab.Funcs[i]
+		if len(f.FuncDataOffsets) != f.NumFuncData || f.FuncDataSize() != 4*f.NumFuncData {
+			t.Fatalf("%s: %d FuncDataOffsets, FuncDataSize %d; want %d, %d", f.Name, len(f.FuncDataOffsets), f.FuncDataSize(), f.NumFuncData, 4*f.NumFuncData)
+		}
+		for _, off := range f.FuncDataOffsets {
+			if off != NoFuncData && gofunc+int(off) >= len(lt.Data) {
+				t.Fatalf("%s: funcdata offset %#x past the end of go:func.*", f.Name, off)
+			}
+		}
+	}
+	// main.main's args pointer maps, at least.
+	if offs := tab.LookupFunc("main.main").FuncDataOffsets; len(offs) == 0 || offs[0] == NoFuncData {
+		t.Errorf("main.main FuncDataOffsets = %#x; want some", offs)
+	}
+}
+
+// TestFuncLayouts checks that each pclntabFormat's _func fields are
+// distinct and all precede the packed field.
+func TestFuncLayouts(t *testing.T) {
+	for _, pf := range pclntabFormats {
+		l := pf.layout
+		seen := map[uint32]bool{}
+		for _, n := range []uint32{l.nameOff, l.args, l.deferReturn, l.pcsp, l.pcfile, l.pcln, l.npcdata, l.cuOffset, l.startLine} {
+			if n == 0 {
+				continue
+			}
+			if seen[n] || n >= l.packed {
+				t.Errorf("%s: bad or duplicate field %d with packed field %d", pf.release, n, l.packed)
+			}
+			seen[n] = true
+		}
+		if len(seen) != int(l.packed)-1 {
+			t.Errorf("%s: %d fields before packed field %d", pf.release, len(seen), l.packed)
+		}
+	}
+}
+
+func TestArgSize(t *testing.T) {
+	for _, tt := range []struct {
+		goarch string
+		want   int
+	}{
+		{"amd64", 8},
+		{"386", 4},
+	} {
+		tab := testTable(t, tt.goarch)
+		// Just the receiver.
+		if got := tab.LookupFunc("main.(*T).Inc").ArgSize; got != tt.want {
+			t.Errorf("%s: main.(*T).Inc ArgSize = %d; want %d", tt.goarch, got, tt.want)
+		}
+		if got := tab.LookupFunc("main.main").ArgSize; got != 0 {
+			t.Errorf("%s: main.main ArgSize = %d; want 0", tt.goarch, got)
+		}
+	}
+}
+
+func TestMaxStack(t *testing.T) {
+	tab := testTable(t, "amd64")
+	// main.main calls functions, so has a frame.
+	if got := tab.LookupFunc("main.main").MaxStack(); got <= 0 || got%8 != 0 {
+		t.Errorf("main.main MaxStack = %d; want a positive multiple of 8", got)
+	}
+	// The largest are the runtime's reflectcall helpers, up to
+	// runtime.call1073741824.
+	for i := range tab.Funcs {
+		f := &tab.Funcs[i]
+		if got := f.MaxStack(); got < 0 || int64(got) > 1<<31 {
+			t.Fatalf("%s: MaxStack = %d", f.Name, got)
+		}
+	}
+}
+
+func TestTableRanges(t *testing.T) {
+	tab := testTable(t, "amd64")
+	pctab := len(tab.go12line.pctab)
+	owners := map[int]int{} // pcln table offset => number of funcs
+	for i := range tab.Funcs {
+		f := &tab.Funcs[i]
+		off, n := f.TableRangePCLn()
+		if off != int(f.OffPCLn) || n != f.TableSizePCLn() || off+n >= pctab {
+			t.Fatalf("%s: TableRangePCLn = %d, %d; want %d, %d within %d bytes", f.Name, off, n, f.OffPCLn, f.TableSizePCLn(), pctab)
+		}
+		if off != 0 {
+			owners[off]++
+		}
+		for j := 0; j < f.NumPCData; j++ {
+			if off, n := f.TableRangePCData(j); off+n >= pctab {
+				t.Fatalf("%s: TableRangePCData(%d) = %d, %d past the end", f.Name, j, off, n)
+			}
+		}
+	}
+	var shared bool
+	for _, n := range owners {
+		shared = shared || n > 1
+	}
+	if !shared {
+		t.Error("no functions share a pcln table")
+	}
+}
+
+func TestRegionSizes(t *testing.T) {
+	lt := testTable(t, "amd64").go12line
+	regions := lt.RegionSizes()
+	if got, want := regions["header"], 8+8*8; got != want {
+		t.Errorf("header = %d; want %d", got, want)
+	}
+	sum := 0
+	for name, n := range regions {
+		if n <= 0 {
+			t.Errorf("%s = %d", name, n)
+		}
+		sum += n
+	}
+	// The _func structs follow the functab.
+	size, err := lt.DataSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum >= size {
+		t.Errorf("regions total %d; want less than the pclntab size %d", sum, size)
+	}
+}
+
+// TestEntryPCs checks the entry PCs, which since Go 1.18 are offsets
+// from the text start, against the ELF symbol table, and that
+// looking up PCs finds the functions containing them.
+func TestEntryPCs(t *testing.T) {
+	ef, err := elf.Open(buildTestBinary(t, "linux", "amd64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ef.Close()
+	syms, err := ef.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := map[string]uint64{}
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) == elf.STT_FUNC {
+			addr[s.Name] = s.Value
+		}
+	}
+	tab := testTable(t, "amd64")
+	for _, name := range []string{"main.main", "main.(*T).Inc", "runtime.main", "fmt.Println"} {
+		f := tab.LookupFunc(name)
+		if f == nil {
+			t.Errorf("%s not found", name)
+			continue
+		}
+		if f.Entry != addr[name] {
+			t.Errorf("%s Entry = %#x; want %#x", name, f.Entry, addr[name])
+		}
+		for _, pc := range []uint64{f.Entry, f.End - 1} {
+			if got := tab.PCToFunc(pc); got == nil || got.Name != name {
+				t.Errorf("PCToFunc(%#x) = %v; want %s", pc, got, name)
+			}
+		}
+	}
+}
+
+func TestFileSizes(t *testing.T) {
+	tab := testTable(t, "amd64")
+	textSize := func(f *Func) int64 { return int64(f.End - f.Entry) }
+	sizes, err := tab.FileSizes(textSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total, want int64
+	for _, n := range sizes {
+		total += n
+	}
+	for i := range tab.Funcs {
+		f := &tab.Funcs[i]
+		want += int64(f.End-f.Entry) + int64(f.TableSizePCFile()+f.TableSizePCLn())
+	}
+	if total != want {
+		t.Errorf("FileSizes total %d bytes; want %d", total, want)
+	}
+
+	lazy, err := NewLazyTable(tab.go12line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lazySizes, err := lazy.FileSizes(textSize); err != nil || !reflect.DeepEqual(lazySizes, sizes) {
+		t.Errorf("lazy FileSizes = %d files, %v; want the same %d files", len(lazySizes), err, len(sizes))
+	}
+
+	// Clamping the last function's text takes its bytes off the
+	// total.
+	last := &tab.Funcs[len(tab.Funcs)-1]
+	clamped, err := tab.FileSizes(func(f *Func) int64 {
+		if f.Entry == last.Entry {
+			return 0
+		}
+		return textSize(f)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clampedTotal int64
+	for _, n := range clamped {
+		clampedTotal += n
+	}
+	if got, want := clampedTotal, total-textSize(last); got != want {
+		t.Errorf("FileSizes total with the last function clamped = %d; want %d", got, want)
+	}
+	var mainGo int64
+	for file, n := range sizes {
+		if filepath.Base(file) == "main.go" && !strings.Contains(file, "/runtime/") {
+			mainGo += n
+		}
+	}
+	if mainGo == 0 {
+		t.Errorf("no bytes from main.go in %d files", len(sizes))
+	}
+}
+
+func TestForeachLine(t *testing.T) {
+	tab := testTable(t, "amd64")
+	f := tab.LookupFunc("main.main")
+	next := f.Entry
+	var n int
+	tab.go12line.ForeachLine(f, func(pcStart, pcEnd uint64, file string, line int) {
+		if pcStart != next || pcEnd <= pcStart || pcEnd > f.End {
+			t.Fatalf("range %d = [%#x, %#x); want one starting at %#x within [%#x, %#x)", n, pcStart, pcEnd, next, f.Entry, f.End)
+		}
+		if wantFile, wantLine, _ := tab.PCToLine(pcStart); file != wantFile || line != wantLine {
+			t.Errorf("[%#x, %#x) = %s:%d; PCToLine says %s:%d", pcStart, pcEnd, file, line, wantFile, wantLine)
+		}
+		next = pcEnd
+		n++
+	})
+	if n < 2 {
+		t.Errorf("main.main has %d lines; want several", n)
+	}
+}
+
+func TestVersionNotPclntab(t *testing.T) {
+	if got := NewLineTable([]byte("not a pclntab at all"), 0).Version(); got != "go1.1" {
+		t.Errorf("Version = %q; want go1.1", got)
+	}
+}
+
+// TestQuantum checks that function sizes and pc-value tables agree
+// with the ELF symbol table on arm64, whose PC quantum is 4.
+func TestQuantum(t *testing.T) {
+	ef, err := elf.Open(buildTestBinary(t, "linux", "arm64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ef.Close()
+	syms, err := ef.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	symSize := map[uint64]uint64{}
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) == elf.STT_FUNC {
+			symSize[s.Value] = s.Size
+		}
+	}
+	tab := testTable(t, "arm64")
+	if q := tab.go12line.quantum; q != 4 {
+		t.Fatalf("quantum = %d; want 4", q)
+	}
+	for i := range tab.Funcs {
+		f := &tab.Funcs[i]
+		// Linker-generated markers like go:textfipsstart have
+		// nominal sizes.
+		if size, ok := symSize[f.Entry]; ok && !strings.HasPrefix(f.Name, "go:") && size != f.End-f.Entry {
+			t.Errorf("%s: size %d; symbol table says %d", f.Name, f.End-f.Entry, size)
+		}
+		var last uint64
+		f.ForeachTableEntry(f.OffPCSP, func(_ int64, _ int, pc uint64, _ int) {
+			if pc%4 != 0 {
+				t.Fatalf("%s: pcsp table has unaligned pc %#x", f.Name, pc)
+			}
+			last = pc
+		})
+		if f.OffPCSP != 0 && last != f.End {
+			t.Errorf("%s: pcsp table ends at %#x; want %#x", f.Name, last, f.End)
+		}
+	}
+}
+
+func TestRegionSizesGo12(t *testing.T) {
+	// A go1.2 format pclntab with no functions: the header, the
+	// functab's end PC, the filetab offset, and a filetab with
+	// just its length.
+	b := make([]byte, 8+8+8+4+4)
+	binary.LittleEndian.PutUint32(b, go12magic)
+	b[6], b[7] = 1, 8 // quantum, ptrsize
+	binary.LittleEndian.PutUint32(b[24:], 28)
+	binary.LittleEndian.PutUint32(b[28:], 1)
+	got := NewLineTable(b, 0).RegionSizes()
+	want := map[string]int{"header": 20, "functab": 8, "filetab": 4}
+	if len(got) != len(want) {
+		t.Fatalf("RegionSizes = %v; want %v", got, want)
+	}
+	for name, n := range want {
+		if got[name] != n {
+			t.Errorf("RegionSizes = %v; want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestFuncDataSizes(t *testing.T) {
+	for _, goarch := range []string{"amd64", "arm64"} {
+		ef, err := elf.Open(buildTestBinary(t, "linux", goarch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		syms, err := ef.Symbols()
+		ef.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var gofuncSize uint64
+		for _, s := range syms {
+			if s.Name == "go:func.*" {
+				gofuncSize = s.Size
+			}
+		}
+		tab := testTable(t, goarch)
+		seen := map[uint32]bool{}
+		var total int
+		for i := range tab.Funcs {
+			f := &tab.Funcs[i]
+			sizes := f.FuncDataSizes()
+			if len(sizes) != f.NumFuncData {
+				t.Fatalf("%s: %s: %d FuncDataSizes; want %d", goarch, f.Name, len(sizes), f.NumFuncData)
+			}
+			for j, off := range f.FuncDataOffsets {
+				if off != NoFuncData && !seen[off] {
+					seen[off] = true
+					total += sizes[j]
+				}
+			}
+		}
+		// The last funcdata's end is estimated.
+		if d := total - int(gofuncSize); d < -16 || d > 16 {
+			t.Errorf("%s: funcdata total %d bytes; go:func.* is %d", goarch, total, gofuncSize)
+		}
+	}
+}
+
+func TestPCDataName(t *testing.T) {
+	lt := testTable(t, "amd64").go12line
+	for n, want := range map[int]string{0: "unsafepoint", 1: "stackmap", 2: "inltree", 99: ""} {
+		if got := lt.PCDataName(n); got != want {
+			t.Errorf("PCDataName(%d) = %q; want %q", n, got, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		version version
+		n       int
+		want    string
+	}{
+		{ver12, 0, ""},
+		{ver116, 2, "inltree"},
+		{ver116, 3, ""},
+		{ver118, 3, "arglive"},
+		{ver118, 4, ""},
+		{ver120, 4, "panicbounds"},
+		{ver120, 5, ""},
+	} {
+		lt := &LineTable{version: tt.version}
+		if got := lt.PCDataName(tt.n); got != tt.want {
+			t.Errorf("version %d: PCDataName(%d) = %q; want %q", tt.version, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestStackObjectsSize(t *testing.T) {
+	tab := testTable(t, "amd64")
+	var n int
+	for i := range tab.Funcs {
+		f := &tab.Funcs[i]
+		size := f.StackObjectsSize()
+		if size == 0 {
+			continue
+		}
+		n++
+		if (size-8)%16 != 0 || size > f.FuncDataSizes()[FuncDataStackObjects] {
+			t.Fatalf("%s: StackObjectsSize = %d; funcdata size %d", f.Name, size, f.FuncDataSizes()[FuncDataStackObjects])
+		}
+	}
+	if n == 0 {
+		t.Error("no functions with stack objects")
+	}
+}
+
+func TestFuncDataPadding(t *testing.T) {
+	for _, tt := range []struct {
+		version          version
+		ptrsize          uint32
+		npcdata, padding int
+	}{
+		{ver116, 8, 1, 0}, // 44 byte header
+		{ver116, 8, 2, 4},
+		{ver116, 4, 1, 0},
+		{ver116, 4, 2, 0},
+		{ver118, 8, 1, 0},
+		{ver118, 8, 2, 0},
+	} {
+		lt := &LineTable{version: tt.version, ptrsize: tt.ptrsize, binary: binary.LittleEndian}
+		lt.Data = make([]byte, 128)
+		fd := funcData{t: lt, data: lt.Data}
+		npcdataOff := fd.entrySize() + (fd.layout().npcdata-1)*4
+		binary.LittleEndian.PutUint32(lt.Data[npcdataOff:], uint32(tt.npcdata))
+		f := &Func{LineTable: lt, NumPCData: tt.npcdata, NumFuncData: 2, funcDataBytes: lt.Data}
+		if got := f.FuncDataPadding(); got != tt.padding {
+			t.Errorf("version %d, ptrsize %d, npcdata %d: FuncDataPadding = %d; want %d", tt.version, tt.ptrsize, tt.npcdata, got, tt.padding)
+		}
+		if got, want := f.FuncDataSize(), 2*int(tt.ptrsize); tt.version < ver118 && got != want {
+			t.Errorf("version %d, ptrsize %d: FuncDataSize = %d; want %d", tt.version, tt.ptrsize, got, want)
+		}
+	}
+}
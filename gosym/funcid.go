@@ -0,0 +1,62 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gosym
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// funcIDNames are the names of the runtime's special function IDs
+// (funcID_* in runtime/symtab.go, later FuncID_* in
+// internal/abi/symtab.go), by the Go release that introduced each
+// list. The IDs are their indexes.
+var funcIDNames = []struct {
+	minor int // Go 1.minor
+	names []string
+}{
+	{18, []string{"normal", "abort", "asmcgocall", "asyncPreempt", "cgocallback", "debugCallV2", "gcBgMarkWorker", "goexit", "gogo", "gopanic", "handleAsyncEvent", "mcall", "morestack", "mstart", "panicwrap", "rt0_go", "runfinq", "runtime_main", "sigpanic", "systemstack", "systemstack_switch", "wrapper"}},
+	{23, []string{"normal", "abort", "asmcgocall", "asyncPreempt", "cgocallback", "corostart", "debugCallV2", "gcBgMarkWorker", "goexit", "gogo", "gopanic", "handleAsyncEvent", "mcall", "morestack", "mstart", "panicwrap", "rt0_go", "runfinq", "runtime_main", "sigpanic", "systemstack", "systemstack_switch", "wrapper"}},
+	{25, []string{"normal", "abort", "asmcgocall", "asyncPreempt", "cgocallback", "corostart", "debugCallV2", "gcBgMarkWorker", "goexit", "gogo", "gopanic", "handleAsyncEvent", "mcall", "morestack", "mstart", "panicwrap", "rt0_go", "runtime_main", "runFinalizers", "runCleanups", "sigpanic", "systemstack", "systemstack_switch", "wrapper"}},
+}
+
+// FuncIDName returns the name of the runtime's special function ID
+// id, such as "mcall" or "wrapper", in a binary built by goVersion
+// (as in its build info, e.g. "go1.22.1"). The IDs were renumbered
+// as they were added, so an unknown or missing goVersion uses the
+// latest known IDs. Releases before Go 1.18 aren't known, and IDs
+// not in the table are formatted as numbers.
+func FuncIDName(id int, goVersion string) string {
+	names := funcIDNames[len(funcIDNames)-1].names
+	if minor, ok := goMinor(goVersion); ok {
+		if minor < funcIDNames[0].minor {
+			names = nil
+		}
+		for _, l := range funcIDNames {
+			if minor >= l.minor {
+				names = l.names
+			}
+		}
+	}
+	if id >= 0 && id < len(names) {
+		return names[id]
+	}
+	return fmt.Sprintf("funcID(%d)", id)
+}
+
+// goMinor returns the minor version of a Go 1.x release name, such
+// as 22 for "go1.22.1" or "go1.23rc1".
+func goMinor(goVersion string) (minor int, ok bool) {
+	v, ok := strings.CutPrefix(goVersion, "go1.")
+	if !ok {
+		return 0, false
+	}
+	if i := strings.IndexFunc(v, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		v = v[:i]
+	}
+	minor, err := strconv.Atoi(v)
+	return minor, err == nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gosym
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFuncIDName(t *testing.T) {
+	for _, tt := range []struct {
+		id        int
+		goVersion string
+		want      string
+	}{
+		{0, "go1.20", "normal"},
+		{5, "go1.22.1", "debugCallV2"},
+		{5, "go1.23rc1", "corostart"},
+		{18, "go1.24", "runtime_main"},
+		{18, "go1.25.0", "runFinalizers"},
+		{21, "go1.22", "wrapper"},
+		{5, "", "corostart"},
+		{1, "go1.16", "funcID(1)"},
+		{99, "go1.22", "funcID(99)"},
+	} {
+		if got := FuncIDName(tt.id, tt.goVersion); got != tt.want {
+			t.Errorf("FuncIDName(%d, %q) = %q; want %q", tt.id, tt.goVersion, got, tt.want)
+		}
+	}
+}
+
+// TestFuncIDNamesCurrent checks the special runtime functions of a
+// binary built by the local Go toolchain against their IDs' names.
+func TestFuncIDNamesCurrent(t *testing.T) {
+	tab := testTable(t, "amd64")
+	goVersion := runtime.Version() // presumably the same toolchain
+	for _, name := range []string{"mcall", "morestack", "goexit", "gogo", "systemstack"} {
+		f := tab.LookupFunc("runtime." + name)
+		if f == nil {
+			t.Errorf("runtime.%s not found", name)
+			continue
+		}
+		if got := FuncIDName(int(f.Raw().FuncID), goVersion); got != name {
+			t.Errorf("runtime.%s has funcID %d, %q", name, f.Raw().FuncID, got)
+		}
+	}
+	if got := FuncIDName(int(tab.LookupFunc("runtime.main").Raw().FuncID), goVersion); !strings.HasPrefix(got, "runtime_main") {
+		t.Errorf("runtime.main has funcID %q", got)
+	}
+}
@@ -66,6 +66,14 @@ type LineTable struct {
 	nfiletab    uint32
 	funcNames   map[uint32]string // cache the function names
 	strings     map[uint32]string // interned substrings of Data, keyed by offset
+	gofuncOnce  sync.Once
+	gofunc      int // offset in Data of the go:func.* funcdata, or -1
+
+	funcdataSizesOnce sync.Once
+	funcdataSizes     map[uint32]int // go:func.* offset => size
+
+	nfuncdataOnce sync.Once
+	nfuncdataPack bool // whether the go1.2 format's _funcs end in packed bytes
 	// fileMap varies depending on the version of the object file.
 	// For ver12, it maps the name to the index in the file table.
 	// For ver116, it maps the name to the offset in filetab.
@@ -177,6 +185,76 @@ const (
 	go120magic = 0xfffffff1
 )
 
+// pclntabFormat describes a version of the pclntab format.
+type pclntabFormat struct {
+	magic   uint32
+	version version
+	release string // earliest Go release using the format
+	layout  funcLayout
+}
+
+// A funcLayout describes the _func struct of a pclntab format, as in
+// the runtime's runtime2.go. Fields are numbered as for funcData.field:
+// the index of the 4 byte field after the entry. 0 means absent.
+type funcLayout struct {
+	entryOff bool // the entry is a uint32 offset from the text start, not a PC
+
+	nameOff, args, deferReturn  uint32
+	pcsp, pcfile, pcln, npcdata uint32
+	cuOffset, startLine         uint32
+
+	// packed is the last field. It packs the one byte funcID,
+	// flag, padding, and nfuncdata fields, in memory order, unless
+	// nfuncdataWord is set.
+	packed uint32
+
+	// nfuncdataWord is set if packed may instead be a whole word
+	// of nfuncdata, as it was through Go 1.9. The go1.2 format,
+	// used through Go 1.15, doesn't record which; see
+	// LineTable.nfuncdataPacked.
+	nfuncdataWord bool
+
+	hasFlag bool // the flag byte is present (Go 1.17+) or zero padding
+}
+
+// pclntabFormats are the known pclntab formats. A Go release that
+// changes only the magic number or the _func fields needs only a new
+// entry here.
+//
+// Go 1.21 through at least Go 1.24 use the Go 1.20 format.
+var pclntabFormats = []pclntabFormat{
+	{go12magic, ver12, "go1.2", funcLayout{
+		nameOff: 1, args: 2, deferReturn: 3,
+		pcsp: 4, pcfile: 5, pcln: 6, npcdata: 7,
+		packed: 8, nfuncdataWord: true,
+	}},
+	{go116magic, ver116, "go1.16", funcLayout{
+		nameOff: 1, args: 2, deferReturn: 3,
+		pcsp: 4, pcfile: 5, pcln: 6, npcdata: 7,
+		cuOffset: 8, packed: 9, hasFlag: true,
+	}},
+	{go118magic, ver118, "go1.18", funcLayout{
+		entryOff: true, nameOff: 1, args: 2, deferReturn: 3,
+		pcsp: 4, pcfile: 5, pcln: 6, npcdata: 7,
+		cuOffset: 8, packed: 9, hasFlag: true,
+	}},
+	{go120magic, ver120, "go1.20", funcLayout{
+		entryOff: true, nameOff: 1, args: 2, deferReturn: 3,
+		pcsp: 4, pcfile: 5, pcln: 6, npcdata: 7,
+		cuOffset: 8, startLine: 9, packed: 10, hasFlag: true,
+	}},
+}
+
+// format returns t's pclntabFormat, or nil if it's before Go 1.2.
+func (t *LineTable) format() *pclntabFormat {
+	for i := range pclntabFormats {
+		if pclntabFormats[i].version == t.version {
+			return &pclntabFormats[i]
+		}
+	}
+	return nil
+}
+
 // uintptr returns the pointer-sized value encoded at b.
 // The pointer size is dictated by the table being read.
 func (t *LineTable) uintptr(b []byte) uint64 {
@@ -218,24 +296,15 @@ func (t *LineTable) parsePclnTab() {
 	var possibleVersion version
 	leMagic := binary.LittleEndian.Uint32(t.Data)
 	beMagic := binary.BigEndian.Uint32(t.Data)
-	switch {
-	case leMagic == go12magic:
-		t.binary, possibleVersion = binary.LittleEndian, ver12
-	case beMagic == go12magic:
-		t.binary, possibleVersion = binary.BigEndian, ver12
-	case leMagic == go116magic:
-		t.binary, possibleVersion = binary.LittleEndian, ver116
-	case beMagic == go116magic:
-		t.binary, possibleVersion = binary.BigEndian, ver116
-	case leMagic == go118magic:
-		t.binary, possibleVersion = binary.LittleEndian, ver118
-	case beMagic == go118magic:
-		t.binary, possibleVersion = binary.BigEndian, ver118
-	case leMagic == go120magic:
-		t.binary, possibleVersion = binary.LittleEndian, ver120
-	case beMagic == go120magic:
-		t.binary, possibleVersion = binary.BigEndian, ver120
-	default:
+	for _, pf := range pclntabFormats {
+		switch pf.magic {
+		case leMagic:
+			t.binary, possibleVersion = binary.LittleEndian, pf.version
+		case beMagic:
+			t.binary, possibleVersion = binary.BigEndian, pf.version
+		}
+	}
+	if possibleVersion == verUnknown {
 		return
 	}
 	t.version = possibleVersion
@@ -250,16 +319,22 @@ func (t *LineTable) parsePclnTab() {
 	data := func(word uint32) []byte {
 		return t.Data[offset(word):]
 	}
+	// region returns the table starting at the offset in header
+	// word start, which runs until the next table, at the offset
+	// in header word end.
+	region := func(start, end uint32) []byte {
+		return t.Data[offset(start):offset(end)]
+	}
 
 	switch possibleVersion {
 	case ver118, ver120:
 		t.nfunctab = uint32(offset(0))
 		t.nfiletab = uint32(offset(1))
 		t.textStart = t.PC // use the start PC instead of reading from the table, which may be unrelocated
-		t.funcnametab = data(3)
-		t.cutab = data(4)
-		t.filetab = data(5)
-		t.pctab = data(6)
+		t.funcnametab = region(3, 4)
+		t.cutab = region(4, 5)
+		t.filetab = region(5, 6)
+		t.pctab = region(6, 7)
 		t.funcdata = data(7)
 		t.functab = data(7)
 		functabsize := (int(t.nfunctab)*2 + 1) * t.functabFieldSize()
@@ -267,10 +342,10 @@ func (t *LineTable) parsePclnTab() {
 	case ver116:
 		t.nfunctab = uint32(offset(0))
 		t.nfiletab = uint32(offset(1))
-		t.funcnametab = data(2)
-		t.cutab = data(3)
-		t.filetab = data(4)
-		t.pctab = data(5)
+		t.funcnametab = region(2, 3)
+		t.cutab = region(3, 4)
+		t.filetab = region(4, 5)
+		t.pctab = region(5, 6)
 		t.funcdata = data(6)
 		t.functab = data(6)
 		functabsize := (int(t.nfunctab)*2 + 1) * t.functabFieldSize()
@@ -305,33 +380,52 @@ func (t *LineTable) go12Funcs() []Func {
 	funcs := make([]Func, ft.Count())
 	syms := make([]Sym, len(funcs))
 	for i := range funcs {
-		f := &funcs[i]
-		f.Entry = ft.pc(i)
-		f.End = ft.pc(i + 1)
-		info := t.funcData(uint32(i))
-		f.LineTable = t
-		f.FrameSize = int(info.deferreturn())
-
-		f.funcDataBytes = t.funcdata[t.funcTab().funcOff(i):]
-		f.NumPCData = info.numPCData()
-		f.NumFuncData = info.numFuncData()
-		f.OffPCSP = info.pcsp()
-		f.OffPCFile = info.pcfile()
-		f.OffPCLn = info.pcln()
-
-		syms[i] = Sym{
-			Value:     f.Entry,
-			Type:      'T',
-			Name:      t.funcName(info.nameOff()),
-			GoType:    0,
-			Func:      f,
-			goVersion: t.version,
-		}
-		f.Sym = &syms[i]
+		info := t.go12Func(ft, i, &funcs[i], &syms[i])
+		syms[i].Name = t.funcName(info.nameOff())
 	}
 	return funcs
 }
 
+// go12Func decodes the ith function of ft into f and its symbol sym,
+// except for the symbol's name, and returns its funcData.
+func (t *LineTable) go12Func(ft funcTab, i int, f *Func, sym *Sym) funcData {
+	f.Entry = ft.pc(i)
+	f.End = ft.pc(i + 1)
+	info := t.funcData(uint32(i))
+	f.LineTable = t
+	f.FrameSize = int(info.deferreturn())
+	f.ArgSize = int(info.args())
+	if t.version >= ver116 {
+		// The go1.2 format doesn't say whether this is the frame
+		// size, as in Go 1.2, or the deferreturn offset.
+		f.DeferReturn = info.deferreturn()
+	}
+
+	f.funcDataBytes = t.funcdata[ft.funcOff(i):]
+	f.NumPCData = info.numPCData()
+	f.NumFuncData = info.numFuncData()
+	if t.version >= ver118 {
+		f.FuncDataOffsets = info.funcDataOffsets()
+	}
+	f.OffPCSP = info.pcsp()
+	f.OffPCFile = info.pcfile()
+	f.OffPCLn = info.pcln()
+	if t.format().layout.hasFlag {
+		f.Flag = FuncFlag(info.packedByte(1)) // always 0 before Go 1.17
+	}
+	f.StartLine = int(info.startLine())
+
+	*sym = Sym{
+		Value:     f.Entry,
+		Type:      'T',
+		GoType:    0,
+		Func:      f,
+		goVersion: t.version,
+	}
+	f.Sym = sym
+	return info
+}
+
 // findFunc returns the funcData corresponding to the given program counter.
 func (t *LineTable) findFunc(pc uint64) funcData {
 	ft := t.funcTab()
@@ -456,7 +550,7 @@ func (f funcData) IsZero() bool {
 func (f *funcData) entryPC() uint64 {
 	// In Go 1.18, the first field of _func changed
 	// from a uintptr entry PC to a uint32 entry offset.
-	if f.t.version >= ver118 {
+	if f.layout().entryOff {
 		// TODO: support multiple text sections.
 		// See runtime/symtab.go:(*moduledata).textAddr.
 		return uint64(f.t.binary.Uint32(f.data)) + f.t.textStart
@@ -464,11 +558,15 @@ func (f *funcData) entryPC() uint64 {
 	return f.t.uintptr(f.data)
 }
 
-func (f funcData) nameOff() uint32     { return f.field(1) }
-func (f funcData) deferreturn() uint32 { return f.field(3) }
-func (f funcData) pcfile() uint32      { return f.field(5) }
-func (f funcData) pcln() uint32        { return f.field(6) }
-func (f funcData) cuOffset() uint32    { return f.field(8) }
+func (f funcData) layout() *funcLayout { return &f.t.format().layout }
+
+func (f funcData) nameOff() uint32     { return f.field(f.layout().nameOff) }
+func (f funcData) args() int32         { return int32(f.field(f.layout().args)) }
+func (f funcData) deferreturn() uint32 { return f.field(f.layout().deferReturn) }
+func (f funcData) pcfile() uint32      { return f.field(f.layout().pcfile) }
+func (f funcData) pcln() uint32        { return f.field(f.layout().pcln) }
+func (f funcData) cuOffset() uint32    { return f.optField(f.layout().cuOffset) }
+func (f funcData) startLine() int32    { return int32(f.optField(f.layout().startLine)) }
 
 // field returns the nth field of the _func struct.
 // It panics if n == 0; for n == 0, call f.entryPC.
@@ -477,15 +575,27 @@ func (f funcData) field(n uint32) uint32 {
 	if n == 0 {
 		panic("bad funcdata field")
 	}
+	off := f.entrySize() + (n-1)*4 // subsequent fields are 4 bytes each
+	data := f.data[off:]
+	return f.t.binary.Uint32(data)
+}
+
+// optField is like field, but returns 0 for an absent field n == 0.
+func (f funcData) optField(n uint32) uint32 {
+	if n == 0 {
+		return 0
+	}
+	return f.field(n)
+}
+
+// entrySize returns the size of the _func struct's first field.
+func (f funcData) entrySize() uint32 {
 	// In Go 1.18, the first field of _func changed
 	// from a uintptr entry PC to a uint32 entry offset.
-	sz0 := f.t.ptrsize
-	if f.t.version >= ver118 {
-		sz0 = 4
+	if f.layout().entryOff {
+		return 4
 	}
-	off := sz0 + (n-1)*4 // subsequent fields are 4 bytes each
-	data := f.data[off:]
-	return f.t.binary.Uint32(data)
+	return f.t.ptrsize
 }
 
 // step advances to the next pc, value pair in the encoded table.
@@ -601,6 +711,12 @@ func (t *LineTable) go12PCToFile(pc uint64) (file string) {
 	entry := f.entryPC()
 	filetab := f.pcfile()
 	fno := t.pcvalue(filetab, entry, pc)
+	return t.fileName(f, fno)
+}
+
+// fileName returns the name of file number fno, a value from the
+// pcfile table of the function f.
+func (t *LineTable) fileName(f funcData, fno int32) string {
 	if t.version == ver12 {
 		if fno <= 0 {
 			return ""
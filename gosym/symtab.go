@@ -140,18 +140,33 @@ type Func struct {
 	Params    []*Sym // nil for Go 1.3 and later binaries
 	Locals    []*Sym // nil for Go 1.3 and later binaries
 	FrameSize int
+	ArgSize   int // size of the arguments and results, or ArgsSizeUnknown (Go 1.2+)
 	LineTable *LineTable
 	Obj       *Obj
 
-	OffPCSP     uint32 // pcsp table (offset from pcvalue table)
-	OffPCFile   uint32 // pcfile table (offset from pcvalue table)
-	OffPCLn     uint32 // pcln table (offset from pcvalue table)
-	NumPCData   int    // number of entries in pcdata list
-	NumFuncData int    // number of entries in funcdata list
+	OffPCSP     uint32   // pcsp table (offset from pcvalue table)
+	OffPCFile   uint32   // pcfile table (offset from pcvalue table)
+	OffPCLn     uint32   // pcln table (offset from pcvalue table)
+	NumPCData   int      // number of entries in pcdata list
+	NumFuncData int      // number of entries in funcdata list
+	StartLine   int      // line of the func keyword (Go 1.20+); else 0
+	DeferReturn uint32   // offset from Entry of the deferreturn call, or 0 if none (Go 1.16+)
+	Flag        FuncFlag // Go 1.17+
+
+	// FuncDataOffsets are the offsets of the function's funcdata
+	// from go:func.*, or NoFuncData if missing (Go 1.18+); else nil.
+	FuncDataOffsets []uint32
 
 	funcDataBytes []byte
 }
 
+// ArgsSizeUnknown is the ArgSize of functions whose argument size is
+// unknown, such as assembly functions without Go prototypes.
+const ArgsSizeUnknown = -0x80000000
+
+// NoFuncData is the FuncDataOffsets value of missing funcdata.
+const NoFuncData = ^uint32(0)
+
 // An Obj represents a collection of functions in a symbol table.
 //
 // The exact method of division of a binary into separate Objs is an internal detail
@@ -188,6 +203,7 @@ type Table struct {
 	Objs  []Obj           // for Go 1.2 and later only one Obj in slice
 
 	go12line *LineTable // Go 1.2 line number table
+	lazy     bool       // from NewLazyTable; Funcs not decoded
 }
 
 type sym struct {
@@ -548,6 +564,9 @@ func NewTable(symtab []byte, pcln *LineTable) (*Table, error) {
 // PCToFunc returns the function containing the program counter pc,
 // or nil if there is no such function.
 func (t *Table) PCToFunc(pc uint64) *Func {
+	if t.lazy {
+		return t.go12line.go12PCToFunc(pc)
+	}
 	funcs := t.Funcs
 	for len(funcs) > 0 {
 		m := len(funcs) / 2
@@ -0,0 +1,74 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// writeMarkdown writes the markdown mode's output: a GitHub-flavored
+// markdown table of sizes, keyed by package and What, largest first.
+// If top is positive, only the top largest are listed, followed by a
+// row with the total of the rest.
+func writeMarkdown(w io.Writer, sizes map[RecKey]int64, top int) {
+	keys := make([]RecKey, 0, len(sizes))
+	for k := range sizes {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if sizes[keys[i]] != sizes[keys[j]] {
+			return sizes[keys[i]] > sizes[keys[j]]
+		}
+		if keys[i].Package != keys[j].Package {
+			return keys[i].Package < keys[j].Package
+		}
+		return keys[i].What < keys[j].What
+	})
+	fmt.Fprintln(w, "| Package | What | Size |")
+	fmt.Fprintln(w, "|---|---|--:|")
+	var rest int64
+	for i, k := range keys {
+		if top > 0 && i >= top {
+			rest += sizes[k]
+			continue
+		}
+		pkg := k.Package
+		if pkg == "" {
+			pkg = "(none)"
+		}
+		fmt.Fprintf(w, "| %s | %s | %s |\n", markdownEscape(pkg), markdownEscape(k.What), groupDigits(sizes[k]))
+	}
+	if top > 0 && len(keys) > top {
+		fmt.Fprintf(w, "| (%d more) | | %s |\n", len(keys)-top, groupDigits(rest))
+	}
+}
+
+// markdownEscape escapes s for a markdown table cell.
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// groupDigits formats n with commas between groups of three digits,
+// as in 1,234,567.
+func groupDigits(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	var sb strings.Builder
+	if neg {
+		sb.WriteByte('-')
+	}
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
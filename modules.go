@@ -0,0 +1,169 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"debug/buildinfo"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// ModuleRec is the modules mode output record: the total size of the
+// packages of a single module.
+type ModuleRec struct {
+	// Module is the module path. It's "std" for the standard
+	// library and empty for bytes not belonging to any package.
+	Module string `json:"module"`
+
+	// ModuleVersion is the version of Module the binary was built
+	// with. It's empty for the main module, and the Go version for
+	// the standard library. For replaced modules, it's the version
+	// of the replacement, if any.
+	ModuleVersion string `json:"moduleVersion,omitempty"`
+
+	// Replace is the path of Module's replacement, if it was
+	// replaced.
+	Replace string `json:"replace,omitempty"`
+
+	// BaseVersion is, in diffs, the module's version in the base.
+	BaseVersion string `json:"baseVersion,omitempty"`
+
+	Size int64 `json:"size"`
+}
+
+// moduleSizer accumulates package sizes by module.
+type moduleSizer struct {
+	mods  []*ModuleRec // sorted by descending path length, for longest prefix match
+	main  *ModuleRec   // or nil
+	std   *ModuleRec
+	none  *ModuleRec
+	byPkg map[string]*ModuleRec // cache
+}
+
+func newModuleSizer(bi *buildinfo.BuildInfo) *moduleSizer {
+	ms := &moduleSizer{
+		std:   &ModuleRec{Module: "std"},
+		none:  &ModuleRec{},
+		byPkg: map[string]*ModuleRec{},
+	}
+	if bi == nil {
+		return ms
+	}
+	ms.std.ModuleVersion = bi.GoVersion
+	if bi.Main.Path != "" {
+		ms.main = &ModuleRec{Module: bi.Main.Path}
+		ms.mods = append(ms.mods, ms.main)
+	}
+	for _, dep := range bi.Deps {
+		ms.mods = append(ms.mods, depModuleRec(dep))
+	}
+	sort.SliceStable(ms.mods, func(i, j int) bool {
+		return len(ms.mods[i].Module) > len(ms.mods[j].Module)
+	})
+	return ms
+}
+
+func depModuleRec(dep *debug.Module) *ModuleRec {
+	mr := &ModuleRec{Module: dep.Path, ModuleVersion: dep.Version}
+	if r := dep.Replace; r != nil {
+		mr.Replace = r.Path
+		mr.ModuleVersion = r.Version // empty for directory replacements
+	}
+	return mr
+}
+
+// moduleOf returns the module containing the package pkg.
+func (ms *moduleSizer) moduleOf(pkg string) *ModuleRec {
+	if mr, ok := ms.byPkg[pkg]; ok {
+		return mr
+	}
+	mr := ms.findModule(pkg)
+	ms.byPkg[pkg] = mr
+	return mr
+}
+
+func (ms *moduleSizer) findModule(pkg string) *ModuleRec {
+	if pkg == "" {
+		return ms.none
+	}
+	if pkg == "main" && ms.main != nil {
+		// The main package's symbols are named "main", not by
+		// their import path.
+		return ms.main
+	}
+	for _, mr := range ms.mods {
+		if pkg == mr.Module || strings.HasPrefix(pkg, mr.Module+"/") {
+			return mr
+		}
+	}
+	if first, _, _ := strings.Cut(pkg, "/"); !strings.Contains(first, ".") {
+		// No dot in the first path element; assume it's the
+		// standard library (including its vendor directory).
+		return ms.std
+	}
+	return ms.none
+}
+
+func (ms *moduleSizer) add(pkg string, size int64) {
+	ms.moduleOf(pkg).Size += size
+}
+
+// recs returns the non-zero module sizes, largest first.
+func (ms *moduleSizer) recs() []ModuleRec {
+	var recs []ModuleRec
+	for _, mr := range append(ms.mods, ms.std, ms.none) {
+		if mr.Size != 0 {
+			recs = append(recs, *mr)
+		}
+	}
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].Size > recs[j].Size })
+	return recs
+}
+
+// DiffModules returns the size change of each module going from a
+// to b. Modules whose version changed are reported with their old
+// version in BaseVersion, attributing their growth to the upgrade.
+// The result is sorted by size, smallest (most negative) first.
+func DiffModules(a, b []ModuleRec) []ModuleRec {
+	old := make(map[string]ModuleRec)
+	for _, mr := range a {
+		old[mr.Module] = mr
+	}
+	var recs []ModuleRec
+	for _, mr := range b {
+		om, ok := old[mr.Module]
+		delete(old, mr.Module)
+		mr.Size -= om.Size
+		if ok && om.ModuleVersion != mr.ModuleVersion {
+			mr.BaseVersion = om.ModuleVersion
+			if mr.BaseVersion == "" {
+				mr.BaseVersion = "(none)"
+			}
+		}
+		if mr.Size != 0 || mr.BaseVersion != "" {
+			recs = append(recs, mr)
+		}
+	}
+	// Anything left in old is a module we dropped.
+	for _, om := range old {
+		om.BaseVersion, om.ModuleVersion = om.ModuleVersion, ""
+		om.Size = -om.Size
+		recs = append(recs, om)
+	}
+	sort.SliceStable(recs, func(i, j int) bool {
+		if recs[i].Size != recs[j].Size {
+			return recs[i].Size < recs[j].Size
+		}
+		return recs[i].Module < recs[j].Module
+	})
+	return recs
+}
+
+func readBaseModuleRecs() []ModuleRec {
+	var recs []ModuleRec
+	decodeBase(&recs)
+	return recs
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// atomicFile is a file written to a temporary file in the same
+// directory and renamed into place when closed, so readers never see
+// a partially written file.
+type atomicFile struct {
+	*os.File
+	path string
+	done bool
+}
+
+func createAtomic(path string) (*atomicFile, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	// CreateTemp uses mode 0600; use what os.Create would (minus umask).
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &atomicFile{File: tmp, path: path}, nil
+}
+
+// Close closes the temporary file and renames it into place.
+// On failure the temporary file is removed.
+func (f *atomicFile) Close() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	err := f.File.Close()
+	if err == nil {
+		err = os.Rename(f.File.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.File.Name())
+	}
+	return err
+}
+
+// abort removes the temporary file if it hasn't been renamed into
+// place yet.
+func (f *atomicFile) abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.File.Close()
+	os.Remove(f.File.Name())
+}
+
+// gzipWriteCloser is a WriteCloser gzipping what's written to it
+// into w, which it closes when closed.
+type gzipWriteCloser struct {
+	*gzip.Writer
+	w io.WriteCloser
+}
+
+func newGzipWriteCloser(w io.WriteCloser) gzipWriteCloser {
+	return gzipWriteCloser{gzip.NewWriter(w), w}
+}
+
+func (g gzipWriteCloser) Close() error {
+	err := g.Writer.Close()
+	if err2 := g.w.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// atExit holds cleanups for exit to run, such as removing a partly
+// written --out file, as os.Exit doesn't run deferred calls.
+var atExit []func()
+
+// exit runs the atExit cleanups and exits with code.
+func exit(code int) {
+	for _, fn := range atExit {
+		fn()
+	}
+	os.Exit(code)
+}
+
+// fatal is like log.Fatal, but runs the atExit cleanups first.
+func fatal(v ...any) {
+	log.Print(v...)
+	exit(1)
+}
+
+// fatalf is like log.Fatalf, but runs the atExit cleanups first.
+func fatalf(format string, v ...any) {
+	log.Printf(format, v...)
+	exit(1)
+}
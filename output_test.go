@@ -0,0 +1,73 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.json")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := createAtomic(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("partial")
+	f.abort()
+	if got, _ := os.ReadFile(path); string(got) != "old" {
+		t.Errorf("after abort, file = %q; want %q", got, "old")
+	}
+
+	f, err = createAtomic(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("new")
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.abort() // no-op after Close
+	if got, _ := os.ReadFile(path); string(got) != "new" {
+		t.Errorf("after Close, file = %q; want %q", got, "new")
+	}
+	if ents, _ := os.ReadDir(dir); len(ents) != 1 {
+		t.Errorf("dir has %d entries; want just the output file", len(ents))
+	}
+}
+
+func TestGzipWriteCloser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.json.gz")
+	f, err := createAtomic(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newGzipWriteCloser(f)
+	io.WriteString(w, "hello")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing w closed and renamed the atomic file.
+	gf, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gf.Close()
+	zr, err := gzip.NewReader(gf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(zr); err != nil || string(got) != "hello" {
+		t.Errorf("contents = %q, %v; want %q", got, err, "hello")
+	}
+}
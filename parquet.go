@@ -0,0 +1,108 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// A dbColumn is a column of the Bin table.
+type dbColumn struct {
+	name, sqlType string
+}
+
+// binColumns returns the columns of the Bin table, with an Arch
+// column if multiArch.
+func binColumns(multiArch bool) []dbColumn {
+	cols := []dbColumn{{"Func", "varchar"}, {"Pkg", "varchar"}, {"What", "varchar"}, {"Size", "int64"}}
+	if multiArch {
+		cols = append(cols, dbColumn{"Arch", "varchar"})
+	}
+	for _, c := range selectedColumns {
+		cols = append(cols, dbColumn{c.name, c.sqlType})
+	}
+	return cols
+}
+
+// parquetNode returns the Parquet column for values of the SQL type
+// sqlType. All columns are optional, as any may be NULL.
+func parquetNode(sqlType string) parquet.Node {
+	switch sqlType {
+	case "int", "int64":
+		return parquet.Optional(parquet.Int(64))
+	case "real":
+		return parquet.Optional(parquet.Leaf(parquet.DoubleType))
+	case "bool":
+		return parquet.Optional(parquet.Leaf(parquet.BooleanType))
+	}
+	return parquet.Optional(parquet.String())
+}
+
+// parquetValue returns v, a value of the SQL type sqlType, as the
+// Parquet value of column col.
+func parquetValue(sqlType string, v any, col int) parquet.Value {
+	if v == nil {
+		return parquet.NullValue().Level(0, 0, col)
+	}
+	switch sqlType {
+	case "int", "int64", "real", "bool":
+	default:
+		if _, ok := v.(string); !ok {
+			v = fmt.Sprint(v)
+		}
+	}
+	return parquet.ValueOf(v).Level(0, 1, col)
+}
+
+// writeParquet writes rows, whose values may be nil (NULL), strings,
+// bools, floats, or integers, as a zstd compressed Parquet file of
+// cols to w.
+func writeParquet(w io.Writer, cols []dbColumn, rows [][]any) error {
+	root := &orderedGroup{Group: parquet.Group{}}
+	for _, c := range cols {
+		n := parquetNode(c.sqlType)
+		root.Group[c.name] = n
+		root.fields = append(root.fields, orderedField{n, c.name})
+	}
+	pw := parquet.NewWriter(w,
+		parquet.NewSchema("schema", root),
+		parquet.Compression(&parquet.Zstd),
+		parquet.CreatedBy("shotizam", "", ""))
+	prow := make(parquet.Row, len(cols))
+	for _, row := range rows {
+		for i, c := range cols {
+			prow[i] = parquetValue(c.sqlType, row[i], i)
+		}
+		if _, err := pw.WriteRows([]parquet.Row{prow}); err != nil {
+			return err
+		}
+	}
+	return pw.Close()
+}
+
+// orderedGroup is a parquet.Group whose fields are in the order of
+// fields, like the Bin table's columns, rather than sorted by name.
+type orderedGroup struct {
+	parquet.Group
+	fields []parquet.Field
+}
+
+func (g *orderedGroup) Fields() []parquet.Field { return g.fields }
+
+type orderedField struct {
+	parquet.Node
+	name string
+}
+
+func (f orderedField) Name() string { return f.name }
+
+// Value is only used to write Go values, which writeParquet doesn't.
+func (f orderedField) Value(base reflect.Value) reflect.Value {
+	panic("unreachable")
+}
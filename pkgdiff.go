@@ -0,0 +1,75 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// WhatDiff is the size change of one What category of a package.
+type WhatDiff struct {
+	What string
+	Base int64 // size in the base binary
+	Size int64 // size in the current binary
+}
+
+// PkgDiff returns the sizes of package pkg in a and b, broken down
+// by What, including categories whose size didn't change. The result
+// is sorted by size change, smallest (most negative) first.
+func PkgDiff(a, b []Rec, pkg string) []WhatDiff {
+	base, cur := pkgWhatSizes(a, pkg), pkgWhatSizes(b, pkg)
+	var diffs []WhatDiff
+	for what, size := range cur {
+		diffs = append(diffs, WhatDiff{What: what, Base: base[what], Size: size})
+	}
+	for what, size := range base {
+		if _, ok := cur[what]; !ok {
+			diffs = append(diffs, WhatDiff{What: what, Base: size})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		di, dj := diffs[i].Size-diffs[i].Base, diffs[j].Size-diffs[j].Base
+		if di != dj {
+			return di < dj
+		}
+		return diffs[i].What < diffs[j].What
+	})
+	return diffs
+}
+
+// pkgWhatSizes returns the sizes of the records of package pkg,
+// summed by What.
+func pkgWhatSizes(recs []Rec, pkg string) map[string]int64 {
+	m := make(map[string]int64)
+	for _, r := range recs {
+		if r.Package == pkg {
+			m[r.What] += r.Size
+		}
+	}
+	return m
+}
+
+func writePkgDiff(w io.Writer, pkg string, diffs []WhatDiff) {
+	var tot WhatDiff
+	fmt.Fprintf(w, "%s:\n", pkg)
+	fmt.Fprintf(w, "%-20s %10s %10s %10s %8s\n", "what", "base", "current", "change", "pct")
+	for _, d := range diffs {
+		writeWhatDiff(w, d)
+		tot.Base += d.Base
+		tot.Size += d.Size
+	}
+	tot.What = "total"
+	writeWhatDiff(w, tot)
+}
+
+func writeWhatDiff(w io.Writer, d WhatDiff) {
+	pct := "new"
+	if d.Base != 0 {
+		pct = fmt.Sprintf("%+.1f%%", float64(d.Size-d.Base)*100/float64(d.Base))
+	}
+	fmt.Fprintf(w, "%-20s %10d %10d %+10d %8s\n", d.What, d.Base, d.Size, d.Size-d.Base, pct)
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bradfitz/shotizam/gosym"
+)
+
+// A pkgTree is a node of the tree mode's tree: the elements of a
+// package's import path, then its functions' receivers and base
+// names. Records not of a function are leaves named by their What,
+// under their package, if any.
+type pkgTree struct {
+	name string
+	sep  string // joining name to its parent's when collapsed
+	size int64  // including children
+	kids map[string]*pkgTree
+}
+
+// add adds a record of size bytes, belonging to fn (which may be
+// nil), to the tree rooted at n.
+func (n *pkgTree) add(fn *gosym.Func, name, pkg, what string, size int64) {
+	n.size += size
+	if pkg != "" {
+		for _, elem := range pkgPathElems(pkg) {
+			n = n.child(elem, "/")
+			n.size += size
+		}
+	}
+	var parts []string
+	switch {
+	case fn != nil:
+		if recv := fn.ReceiverName(); recv != "" {
+			parts = append(parts, recv)
+		}
+		parts = append(parts, fn.BaseName())
+	case name != "":
+		parts = append(parts, what, name)
+	default:
+		parts = append(parts, what)
+	}
+	sep := "."
+	if fn == nil {
+		sep = " "
+	}
+	for _, p := range parts {
+		n = n.child(p, sep)
+		n.size += size
+	}
+}
+
+// pkgPathElems returns the elements of the import path pkg. Slashes
+// in brackets, as in the type arguments of the packages of some
+// generic symbols, don't separate elements.
+func pkgPathElems(pkg string) []string {
+	path, rest := pkg, ""
+	if i := strings.IndexByte(pkg, '['); i >= 0 {
+		path, rest = pkg[:i], pkg[i:]
+	}
+	elems := strings.Split(path, "/")
+	elems[len(elems)-1] += rest
+	return elems
+}
+
+// child returns n's child named name, creating it if needed.
+func (n *pkgTree) child(name, sep string) *pkgTree {
+	if c, ok := n.kids[name]; ok {
+		return c
+	}
+	if n.kids == nil {
+		n.kids = map[string]*pkgTree{}
+	}
+	c := &pkgTree{name: name, sep: sep}
+	n.kids[name] = c
+	return c
+}
+
+// writeTree writes the tree mode's output: the tree rooted at root,
+// indented, with each node's size and percentage of the root's.
+// Chains of single children are collapsed into one line, as in
+// github.com/foo/bar. If top is positive, only the top largest
+// children of each node are listed, followed by a line with the
+// total of the rest.
+func writeTree(w io.Writer, root *pkgTree, top int) {
+	width := len(strconv.FormatInt(root.size, 10))
+	var write func(n *pkgTree, depth int)
+	write = func(n *pkgTree, depth int) {
+		name := n.name
+		for depth > 0 && len(n.kids) == 1 {
+			for _, c := range n.kids {
+				name += c.sep + c.name
+				n = c
+			}
+		}
+		writeTreeLine(w, width, n.size, root.size, depth, name)
+		kids := make([]*pkgTree, 0, len(n.kids))
+		for _, c := range n.kids {
+			kids = append(kids, c)
+		}
+		sort.Slice(kids, func(i, j int) bool {
+			if kids[i].size != kids[j].size {
+				return kids[i].size > kids[j].size
+			}
+			return kids[i].name < kids[j].name
+		})
+		var rest int64
+		for i, c := range kids {
+			if top > 0 && i >= top {
+				rest += c.size
+				continue
+			}
+			write(c, depth+1)
+		}
+		if top > 0 && len(kids) > top {
+			writeTreeLine(w, width, rest, root.size, depth+1, fmt.Sprintf("(%d more)", len(kids)-top))
+		}
+	}
+	write(root, 0)
+}
+
+// writeTreeLine writes a line of the tree mode's output.
+func writeTreeLine(w io.Writer, width int, size, total int64, depth int, name string) {
+	fmt.Fprintf(w, "%*d (%6.02f%%) %s%s\n", width, size, float64(size)*100/float64(total), strings.Repeat("  ", depth), name)
+}
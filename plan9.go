@@ -0,0 +1,68 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"debug/plan9obj"
+	"errors"
+	"fmt"
+)
+
+// plan9objFile opens a Plan 9 a.out binary. Its pclntab is read-only
+// data at the end of the text segment, found by its symbols.
+func plan9objFile(pf *plan9obj.File, size int64) (*File, error) {
+	syms, err := pf.Symbols()
+	if err != nil {
+		if errors.Is(err, plan9obj.ErrNoSymbols) {
+			return nil, errStripped
+		}
+		return nil, fmt.Errorf("plan9obj symbols: %w", err)
+	}
+	f := &File{Size: size, Arch: plan9Arch(pf.Magic)}
+	var start, end uint64
+	for _, s := range syms {
+		switch s.Name {
+		case "runtime.text":
+			f.TextOffset = s.Value
+		case "runtime.pclntab":
+			start = s.Value
+		case "runtime.epclntab":
+			end = s.Value
+		}
+	}
+	if start == 0 || end <= start {
+		return nil, errors.New("no runtime.pclntab symbol found in Plan 9 binary")
+	}
+	text := pf.Section("text")
+	if text == nil {
+		return nil, errors.New("no text section in Plan 9 binary")
+	}
+	// The text segment is mapped along with the header that
+	// precedes it in the file, at LoadAddress.
+	textStart := pf.LoadAddress + uint64(text.Offset)
+	if start < textStart || end > textStart+uint64(text.Size) {
+		return nil, errors.New("Plan 9 binary's pclntab isn't in its text segment")
+	}
+	f.Gopclntab = make([]byte, end-start)
+	if _, err := text.ReadAt(f.Gopclntab, int64(start-textStart)); err != nil {
+		return nil, err
+	}
+	if f.TextOffset == 0 {
+		f.TextOffset = textStart
+	}
+	return f, nil
+}
+
+func plan9Arch(magic uint32) string {
+	switch magic {
+	case plan9obj.Magic386:
+		return "386"
+	case plan9obj.MagicAMD64:
+		return "amd64"
+	case plan9obj.MagicARM:
+		return "arm"
+	}
+	return fmt.Sprintf("plan9 magic %#x", magic)
+}
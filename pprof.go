@@ -0,0 +1,178 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+)
+
+// pprofBuilder builds the pprof mode's output: a gzipped profile.proto
+// (see github.com/google/pprof/proto/profile.proto) for browsing with
+// go tool pprof. Each sample is the size of a record, labeled with
+// its What, with a stack of the record's function called from its
+// package, so the flame graph groups functions by package. Records
+// not belonging to a function are named by their What. Records with
+// negative sizes, like not-in-file, are left out, as pprof's graphs
+// can't show them, so the total is the size in memory rather than
+// in the file.
+type pprofBuilder struct {
+	strings  []string
+	stringID map[string]int64
+	funcs    []pprofFunc
+	funcID   map[pprofFunc]uint64
+	samples  map[pprofSample]int64 // to size
+	order    []pprofSample         // samples in order of first use
+}
+
+type pprofFunc struct {
+	name, file string
+	line       int64
+}
+
+type pprofSample struct {
+	fn, pkg uint64 // function IDs, which are also location IDs; pkg may be 0
+	what    int64  // string ID
+}
+
+func newPprofBuilder() *pprofBuilder {
+	pb := &pprofBuilder{
+		stringID: map[string]int64{},
+		funcID:   map[pprofFunc]uint64{},
+		samples:  map[pprofSample]int64{},
+	}
+	pb.str("") // string 0 must be empty
+	return pb
+}
+
+// str returns the string table ID of s.
+func (pb *pprofBuilder) str(s string) int64 {
+	if id, ok := pb.stringID[s]; ok {
+		return id
+	}
+	id := int64(len(pb.strings))
+	pb.strings = append(pb.strings, s)
+	pb.stringID[s] = id
+	return id
+}
+
+// fn returns the ID of the function (and its location) f.
+func (pb *pprofBuilder) fn(f pprofFunc) uint64 {
+	if id, ok := pb.funcID[f]; ok {
+		return id
+	}
+	pb.funcs = append(pb.funcs, f)
+	id := uint64(len(pb.funcs))
+	pb.funcID[f] = id
+	return id
+}
+
+// add adds a record of size bytes. fi is the function the bytes
+// belong to, or nil if they're not for a function.
+func (pb *pprofBuilder) add(fi *funcInfo, name, pkg, what string, size int64) {
+	if size <= 0 {
+		return
+	}
+	leaf := pprofFunc{name: name}
+	if fi != nil {
+		file, line, _ := fi.t.PCToLine(fi.fn.Entry)
+		leaf.file, leaf.line = file, int64(line)
+		if fi.fn.StartLine != 0 {
+			leaf.line = int64(fi.fn.StartLine)
+		}
+	}
+	if name == "" {
+		leaf.name = what
+	}
+	s := pprofSample{fn: pb.fn(leaf), what: pb.str(what)}
+	if pkg != "" {
+		s.pkg = pb.fn(pprofFunc{name: pkg})
+	}
+	if _, ok := pb.samples[s]; !ok {
+		pb.order = append(pb.order, s)
+	}
+	pb.samples[s] += size
+}
+
+// write writes the gzipped profile to w.
+func (pb *pprofBuilder) write(w io.Writer) error {
+	// All strings must be in the table before it's written, last.
+	var p, vt protoBuf
+	vt.int64(1, pb.str("size"))
+	vt.int64(2, pb.str("bytes"))
+	p.bytes(1, vt.b) // sample_type
+	whatKey := pb.str("what")
+	for _, s := range pb.order {
+		var sample, locs, label protoBuf
+		locs.uvarint(s.fn)
+		if s.pkg != 0 {
+			locs.uvarint(s.pkg)
+		}
+		sample.bytes(1, locs.b) // location_id, packed
+		var val protoBuf
+		val.uvarint(uint64(pb.samples[s]))
+		sample.bytes(2, val.b) // value, packed
+		label.int64(1, whatKey)
+		label.int64(2, s.what)
+		sample.bytes(3, label.b)
+		p.bytes(2, sample.b)
+	}
+	for i, f := range pb.funcs {
+		id := uint64(i + 1)
+		var loc, line protoBuf
+		loc.varint(1, id)
+		line.varint(1, id)
+		line.int64(2, f.line)
+		loc.bytes(4, line.b)
+		p.bytes(4, loc.b)
+
+		var fn protoBuf
+		fn.varint(1, id)
+		fn.int64(2, pb.str(f.name))
+		fn.int64(3, pb.str(f.name))
+		fn.int64(4, pb.str(f.file))
+		fn.int64(5, f.line)
+		p.bytes(5, fn.b)
+	}
+	for _, s := range pb.strings {
+		p.bytes(6, []byte(s))
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(p.b); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// protoBuf is a protocol buffer message being encoded.
+type protoBuf struct {
+	b []byte
+}
+
+func (p *protoBuf) uvarint(v uint64) {
+	p.b = binary.AppendUvarint(p.b, v)
+}
+
+// varint appends field as a varint.
+func (p *protoBuf) varint(field int, v uint64) {
+	p.uvarint(uint64(field) << 3)
+	p.uvarint(v)
+}
+
+// int64 appends field as an int64, which negative values sign-extend
+// to 10 bytes.
+func (p *protoBuf) int64(field int, v int64) {
+	p.varint(field, uint64(v))
+}
+
+// bytes appends field as a length-delimited field, as for strings,
+// messages, and packed repeated fields.
+func (p *protoBuf) bytes(field int, b []byte) {
+	p.uvarint(uint64(field)<<3 | 2)
+	p.uvarint(uint64(len(b)))
+	p.b = append(p.b, b...)
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// writeProm writes the prom mode's output: sizes, keyed by package and
+// What, and the file's total size, of the binary named bin, in the
+// Prometheus text exposition format, for node_exporter's textfile
+// collector. (Write it with --out, which replaces the file
+// atomically, so the collector never reads a partial file.)
+func writeProm(w io.Writer, bin string, sizes map[RecKey]int64, fileSize int64) {
+	keys := make([]RecKey, 0, len(sizes))
+	for k := range sizes {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Package != keys[j].Package {
+			return keys[i].Package < keys[j].Package
+		}
+		return keys[i].What < keys[j].What
+	})
+	binLabel := promLabelValue(bin)
+	fmt.Fprintln(w, "# HELP go_binary_size_bytes Bytes of a Go binary by package and what they're for.")
+	fmt.Fprintln(w, "# TYPE go_binary_size_bytes gauge")
+	for _, k := range keys {
+		fmt.Fprintf(w, "go_binary_size_bytes{binary=%s,package=%s,what=%s} %d\n",
+			binLabel, promLabelValue(k.Package), promLabelValue(k.What), sizes[k])
+	}
+	fmt.Fprintln(w, "# HELP go_binary_file_size_bytes Size of a Go binary's file.")
+	fmt.Fprintln(w, "# TYPE go_binary_file_size_bytes gauge")
+	fmt.Fprintf(w, "go_binary_file_size_bytes{binary=%s} %d\n", binLabel, fileSize)
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promLabelValue returns s quoted as a Prometheus label value.
+func promLabelValue(s string) string {
+	return `"` + promEscaper.Replace(s) + `"`
+}
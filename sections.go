@@ -0,0 +1,63 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeSections writes the sections mode's output: a line per
+// section of f with its name, its size in the file, and that size
+// as a percentage of the file, separated by tabs. A final "(other)"
+// line has the rest of the file, such as headers and padding.
+func writeSections(w io.Writer, f *File) {
+	var total int64
+	for _, s := range f.Sections {
+		fmt.Fprintf(w, "%s\t%d\t%.2f\n", s.Name, s.FileSize, float64(s.FileSize)*100/float64(f.Size))
+		total += s.FileSize
+	}
+	fmt.Fprintf(w, "(other)\t%d\t%.2f\n", f.Size-total, float64(f.Size-total)*100/float64(f.Size))
+}
+
+// otherSections returns f's sections that are neither data sections
+// nor the text and pclntab, which are accounted for elsewhere.
+func (f *File) otherSections() []SectionSize {
+	skip := map[string]bool{}
+	for _, name := range f.funcSections {
+		skip[name] = true
+	}
+	for _, s := range f.DataSections {
+		skip[s.Name] = true
+	}
+	var other []SectionSize
+	for _, s := range f.Sections {
+		if !skip[s.Name] {
+			other = append(other, s)
+		}
+	}
+	return other
+}
+
+// dwarfSectionName returns the DWARF section name, such as
+// ".debug_info", of the section named name, and whether it's a DWARF
+// section at all. It undoes the different spellings of the object
+// file formats: ELF and PE's compressed .zdebug_info and Mach-O's
+// __DWARF,__debug_info or __DWARF,__zdebug_info. Mach-O section
+// names are truncated to 16 bytes.
+func dwarfSectionName(name string) (string, bool) {
+	name, macho := strings.CutPrefix(name, "__DWARF,__")
+	if macho {
+		name = "." + name
+	}
+	if s, ok := strings.CutPrefix(name, ".zdebug_"); ok {
+		return ".debug_" + s, true
+	}
+	if strings.HasPrefix(name, ".debug_") || macho {
+		return name, true
+	}
+	return "", false
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+)
+
+//go:embed serve.html
+var serveHTML string
+
+var serveIndexTmpl = template.Must(template.New("serve").Funcs(template.FuncMap{"groupDigits": groupDigits}).Parse(serveHTML))
+
+// treeServer is the HTTP handler of the --serve flag. It serves an
+// index of packages at /, the treemap and flame graph pages at
+// /treemap and /flamegraph, and their trees at /treemap.json and
+// /flamegraph.json. A pkg query parameter limits the pages and
+// trees to that package. It also serves the bin-json mode's output
+// at /bin.json. The JSON is served with CORS headers allowing any
+// origin, so notebooks elsewhere can fetch it.
+type treeServer struct {
+	title       string
+	tree, flame *TreeNode // both sorted, and never modified
+	binJSON     []byte
+	mux         *http.ServeMux
+}
+
+// newTreeServer returns a treeServer of the trees tree and flame,
+// whose top levels must be packages, and the Bin table rows of cols,
+// of the binary titled title.
+func newTreeServer(title string, tree, flame *TreeNode, cols []dbColumn, rows [][]any) (*treeServer, error) {
+	var buf bytes.Buffer
+	if err := writeBinJSON(&buf, cols, rows); err != nil {
+		return nil, err
+	}
+	tree.sortBySize()
+	flame.sortBySize()
+	s := &treeServer{title: title, tree: tree, flame: flame, binJSON: buf.Bytes(), mux: http.NewServeMux()}
+	s.mux.HandleFunc("/", s.serveIndex)
+	s.mux.HandleFunc("/treemap", s.servePage(treemapTmpl, tree))
+	s.mux.HandleFunc("/flamegraph", s.servePage(flameGraphTmpl, flame))
+	s.mux.HandleFunc("/treemap.json", s.serveJSON(tree))
+	s.mux.HandleFunc("/flamegraph.json", s.serveJSON(flame))
+	s.mux.HandleFunc("/bin.json", s.serveBinJSON)
+	return s, nil
+}
+
+func (s *treeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// pkgSize is a row of the index page.
+type pkgSize struct {
+	Name string
+	Size int64
+}
+
+func (s *treeServer) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	var pkgs []pkgSize
+	for _, c := range s.tree.Children {
+		pkgs = append(pkgs, pkgSize{c.Name, c.Total()})
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	serveIndexTmpl.Execute(w, struct {
+		Title    string
+		Packages []pkgSize
+	}{s.title, pkgs})
+}
+
+// subtree returns the part of root requested by r's pkg query
+// parameter, and its title, or nil if there's no such package.
+func (s *treeServer) subtree(root *TreeNode, r *http.Request) (*TreeNode, string) {
+	pkg := r.FormValue("pkg")
+	if pkg == "" {
+		return root, s.title
+	}
+	n, ok := root.kids[pkg]
+	if !ok {
+		return nil, ""
+	}
+	return n, s.title + " " + pkg
+}
+
+func (s *treeServer) servePage(tmpl *template.Template, root *TreeNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, title := s.subtree(root, r)
+		if n == nil {
+			http.Error(w, "no such package", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		tmpl.Execute(w, struct {
+			Title string
+			Root  *TreeNode
+		}{title, n})
+	}
+}
+
+func (s *treeServer) serveJSON(root *TreeNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, _ := s.subtree(root, r)
+		if n == nil {
+			http.Error(w, "no such package", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		je := json.NewEncoder(w)
+		je.SetEscapeHTML(false)
+		je.Encode(n)
+	}
+}
+
+func (s *treeServer) serveBinJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write(s.binJSON)
+}
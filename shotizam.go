@@ -7,15 +7,23 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"debug/buildinfo"
 	"debug/elf"
 	"debug/macho"
 	"debug/pe"
+	"debug/plan9obj"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -25,26 +33,192 @@ import (
 
 	"github.com/bradfitz/shotizam/ar"
 	"github.com/bradfitz/shotizam/gosym"
+	"github.com/bradfitz/shotizam/sizes"
 )
 
 var (
-	base    = flag.String("base", "", "base file to diff from; must be in json format")
-	mode    = flag.String("mode", "sql", "output mode; tsv, json, sql, nameinfo")
-	sqlite  = flag.Bool("sqlite", false, "launch SQLite on data (when true, mode flag is ignored)")
-	verbose = flag.Bool("verbose", false, "verbose logging of file parsing")
+	base           = flag.String("base", "", "base file to diff from; must be in json format")
+	mode           = flag.String("mode", "sql", "output mode; tsv, json, yaml, json-nested, modules, treemap-json, treemap, parquet, bin-json, flamegraph, pprof, markdown, top, tree, trace, prom, serve, sql, nameinfo, abiwrappers, buildinfo, sections, files")
+	sqlite         = flag.Bool("sqlite", false, "launch SQLite on data: the sqlite3 binary if it's installed, or else a built-in prompt (when true, mode flag is ignored)")
+	sqlViews       = flag.Bool("sql-views", false, "with sql mode, also create indexes on Bin's Pkg and What, and the PkgTotals, WhatTotals, and FuncTotals views of sizes summed by them")
+	sqldb          = flag.String("sqldb", "", "if non-empty, the SQLite database file to write the sql mode's Bin table to, without needing sqlite3 (the mode flag is ignored)")
+	query          = flag.String("query", "", "with --sqlite, a SQL query of the Bin table to run and print the results of, rather than starting an interactive prompt")
+	verbose        = flag.Bool("verbose", false, "verbose logging of file parsing")
+	validate       = flag.Bool("validate", false, "only check that the binary can be parsed and accounted for, printing a summary")
+	strict         = flag.Bool("strict", false, "make sanity check warnings fatal")
+	debugFile      = flag.String("debug-file", "", "separate ELF debug file with the symbols and pclntab of a stripped binary; defaults to the file named by the binary's .gnu_debuglink section, if found")
+	cSyms          = flag.Bool("ctext-syms", false, "emit a ctext row per non-Go text symbol, rather than one total ctext row")
+	treemapLevels  = flag.String("treemap-levels", "pkg,type,func,what", "comma-separated grouping levels of treemap-json and treemap modes, from: pkg, type, file, func, what")
+	dumpFuncs      = flag.Int("dump-funcs", 0, "if non-zero, print the raw _func structs of the first N functions and exit, for debugging")
+	columns        = flag.String("columns", "", "comma-separated optional per-function columns to add to sql, tsv, json, and yaml output; any of: "+columnNames())
+	out            = flag.String("out", "", "if non-empty, the file to write output to instead of stdout; it is written atomically, and gzipped if the name ends in .gz")
+	gzipOut        = flag.Bool("gzip", false, "gzip the output")
+	serve          = flag.String("serve", "", "if non-empty, an address like :8080 on which to serve an interactive treemap and flame graph, instead of writing output")
+	top            = flag.Int("top", 20, "number of largest rows for markdown and top modes to list, and children of each node for tree mode; 0 lists all")
+	pkgDiff        = flag.String("pkg-diff", "", "if non-empty, the package whose size change versus --base to report, by What")
+	stream         = flag.Bool("stream", false, "decode and emit functions one at a time rather than all up front, to bound memory use on huge binaries; sql, tsv, modules, and files modes only")
+	arch           = flag.String("arch", "", "GOARCH to analyze in a universal (fat) Mach-O binary or multi-architecture ar archive, required if there is more than one; \"all\" analyzes each, tagging rows with their GOARCH")
+	apkLib         = flag.String("apk-lib", "", "path within an APK or other zip file of the Go shared library to analyze, such as lib/arm64-v8a/libgojni.so")
+	dwarfVars      = flag.Bool("dwarf", false, "attribute the sizes of global variables to their packages using the binary's DWARF, as What \"var\" rows")
+	inline         = flag.Bool("inline", false, "attribute the text of inlined calls to the inlined function and its package rather than to the caller; needs a Go 1.18+ binary")
+	maxUnaccounted = flag.Float64("max-unaccounted", 1, "percentage of the file's size that may be left unaccounted for (or counted twice) before warning; 0 disables the check")
 )
 
+func init() {
+	flag.StringVar(out, "o", "", "shorthand for --out")
+	flag.StringVar(out, "output", "", "same as --out")
+}
+
 type File struct {
-	Size       int64
+	Size int64
+
+	// TextOffset is the virtual address of the start of the text,
+	// which the pclntab's PCs are relative to (and, since Go 1.18,
+	// offsets from).
 	TextOffset uint64
-	Gopclntab  []byte
+
+	// TextStart and TextEnd are the virtual addresses of the start
+	// and end of the text section, from the section headers, or 0 if
+	// unknown. Function sizes are clamped to TextEnd. TextStart,
+	// unlike TextOffset, never comes from symbols or the pclntab, so
+	// it can check TextOffset.
+	TextStart, TextEnd uint64
+
+	Gopclntab []byte
+
+	// Arch is the binary's GOARCH, if known.
+	Arch string
+
+	// Others are the other architectures' Files of a
+	// multi-architecture file opened with --arch=all.
+	Others []*File
+
+	// BuildInfo is the binary's embedded build information
+	// (Go version, modules, and build settings), or nil if it
+	// has none.
+	BuildInfo *buildinfo.BuildInfo
+
+	// BuildID is the binary's Go build ID, or empty if it has
+	// none.
+	BuildID string
+	// TextSyms are the text symbols from the binary's regular
+	// (non-Go) symbol table, if present. Their addresses are in
+	// the same address space as TextOffset.
+	TextSyms []Sym
+
+	// DataSections are the sizes of the binary's data sections
+	// that aren't otherwise accounted for.
+	DataSections []SectionSize
+
+	// Sections are the sizes of all the binary's sections, for
+	// the sections mode.
+	Sections []SectionSize
+
+	// Gaps are the sizes of the parts of the file outside its
+	// sections, such as a Mach-O file's header and load commands
+	// ("header"), its segments' bytes not in any section, like all
+	// of __LINKEDIT ("segment:__LINKEDIT"), and the alignment
+	// padding between its segments ("segment-alignment"). Their
+	// names are their rows' What.
+	Gaps []SectionSize
+
+	// funcSections are the names of the Sections holding the text
+	// and pclntab, which are accounted for by function.
+	funcSections []string
+
+	// Vars are the global variables from the binary's DWARF,
+	// if the --dwarf flag is set, or else from its ELF symbol
+	// table.
+	Vars []Var
+
+	// Strings are the Go string constant data, from the binary's
+	// symbol table.
+	Strings []Var
+
+	// Types are the runtime type descriptors, from the binary's
+	// symbol table. Newer Go releases only have a type:* symbol
+	// at the start of them all.
+	Types []Var
+
+	// Itabs are the interface method tables, from the binary's
+	// symbol table, named by their concrete and interface types.
+	// Only older Go releases put itabs in the symbol table.
+	Itabs []Var
+
+	// wasmFuncSizes are the sizes of a wasm module's function
+	// bodies, by function index.
+	wasmFuncSizes []int64
+}
+
+// funcTextSize returns the size of fn's code in f. The last function
+// ends at the pclntab's end of text, which is clamped to the end of
+// f's text section in case it's misread.
+func (f *File) funcTextSize(fn *gosym.Func) int64 {
+	if size, ok := f.wasmFuncSize(fn); ok {
+		return size
+	}
+	end := fn.End
+	if f.TextEnd != 0 && end > f.TextEnd {
+		end = max(f.TextEnd, fn.Entry)
+	}
+	return int64(end - fn.Entry)
+}
+
+// SectionSize is the size of a section of a binary.
+type SectionSize struct {
+	Name     string
+	Addr     uint64 // virtual address
+	Size     int64  // in memory
+	FileSize int64  // in the file; zero for .bss
+}
+
+// errStripped is returned when a binary's pclntab can't be found
+// and it has no symbols either.
+var errStripped = errors.New("binary appears to be stripped (-s/-w); pclntab unavailable")
+
+// Sym is a symbol from a binary's regular symbol table.
+type Sym struct {
+	Name string
+	Addr uint64
+	Size uint64
 }
 
 func Open(ra io.ReaderAt, size int64) (*File, error) {
+	if b, ok, err := decompress(ra, size); ok {
+		if err != nil {
+			return nil, err
+		}
+		return Open(bytes.NewReader(b), int64(len(b)))
+	}
+	f, err := openFormat(ra, size)
+	if err != nil {
+		return nil, err
+	}
+	if bi, err := buildinfo.Read(ra); err == nil {
+		f.BuildInfo = bi
+	}
+	if f.BuildID == "" {
+		f.BuildID = findBuildID(ra, size)
+	}
+	return f, nil
+}
+
+// openFormat opens ra according to its binary format.
+func openFormat(ra io.ReaderAt, size int64) (*File, error) {
+	if isWasm(ra) {
+		return wasmFile(ra, size)
+	}
+	if isXCOFF(ra) {
+		return xcoffFile(ra, size)
+	}
 	mo, err := macho.NewFile(ra)
 	if err == nil {
 		return machoFile(mo, ra, size)
 	}
+	ff, err := macho.NewFatFile(ra)
+	if err == nil {
+		return machoFatFile(ff, ra)
+	}
 	ef, err := elf.NewFile(ra)
 	if err == nil {
 		return elfFile(ef, size)
@@ -54,34 +228,52 @@ func Open(ra io.ReaderAt, size int64) (*File, error) {
 		return peFile(pf, ra, size)
 	}
 
-	if f, ok := arFile(ra); ok {
-		return f, nil
+	p9, err := plan9obj.NewFile(ra)
+	if err == nil {
+		return plan9objFile(p9, size)
+	}
+
+	if f, ok, err := arFile(ra); ok {
+		return f, err
+	}
+	if f, ok, err := zipFile(ra, size); ok {
+		return f, err
 	}
 
 	return nil, fmt.Errorf("unsupported binary format")
 }
 
-func arFile(ra io.ReaderAt) (f *File, ok bool) {
+// arFile opens the go.o member of an ar archive, as made by
+// -buildmode=c-archive. It reports whether ra is such an archive.
+func arFile(ra io.ReaderAt) (f *File, ok bool, err error) {
 	arr, err := ar.NewReader(ra)
 	if err != nil {
-		return nil, false
+		return nil, false, nil
 	}
+	// Multi-architecture archives (as for iOS) have a go.o per
+	// architecture.
+	var files []*File
 	for {
 		af, err := arr.Next()
 		if err != nil {
-			return nil, false
+			break
 		}
 		if af.Name == "go.o" {
 			f, err := Open(af, af.Size)
 			if err == nil {
-				return f, true
+				files = append(files, f)
 			}
 		}
 	}
+	if len(files) == 0 {
+		return nil, false, nil
+	}
+	f, err = selectArch("archive", files)
+	return f, true, err
 }
 
 func elfFile(ef *elf.File, size int64) (*File, error) {
-	f := &File{Size: size}
+	f := &File{Size: size, Arch: elfArch(ef)}
 
 	syms, err := ef.Symbols()
 	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
@@ -93,33 +285,185 @@ func elfFile(ef *elf.File, size int64) (*File, error) {
 			break
 		}
 	}
-	if f.TextOffset == 0 {
-		text := ef.Section(".text")
-		if text != nil {
-			f.TextOffset = text.Offset
+	f.TextSyms = elfTextSyms(ef, syms)
+	f.BuildID = elfBuildID(ef)
+	if text := ef.Section(".text"); text != nil {
+		if f.TextOffset == 0 {
+			// PCs are virtual addresses, which for shared
+			// objects (ET_DYN) needn't match file offsets.
+			f.TextOffset = text.Addr
 		}
+		f.TextStart, f.TextEnd = text.Addr, text.Addr+text.Size
 	}
 	if f.TextOffset == 0 {
 		return nil, errors.New("no runtime.text symbol or .text section in ELF file")
 	}
 
-	pclntab := ef.Section(".gopclntab")
-	if pclntab == nil {
-		pclntab = ef.Section(".data.rel.ro.gopclntab")
-		if pclntab == nil {
-			return nil, errors.New("no .gopclntab or .data.rel.ro.gopclntab section found in ELF file")
+	var b []byte
+	f.funcSections = []string{".text"}
+	if pclntab := ef.Section(".gopclntab"); pclntab != nil {
+		b, err = pclntab.Data()
+		f.funcSections = append(f.funcSections, pclntab.Name)
+	} else if pclntab := ef.Section(".data.rel.ro.gopclntab"); pclntab != nil {
+		b, err = pclntab.Data()
+		f.funcSections = append(f.funcSections, pclntab.Name)
+	} else {
+		// Shared objects may have the pclntab mixed in with the
+		// rest of .data.rel.ro.
+		b, err = elfSymData(ef, syms, "runtime.pclntab", "runtime.epclntab")
+		for _, sym := range syms {
+			if sym.Name == "runtime.pclntab" && int(sym.Section) < len(ef.Sections) {
+				f.funcSections = append(f.funcSections, ef.Sections[sym.Section].Name)
+			}
 		}
 	}
-	b, err := pclntab.Data()
 	if err != nil {
+		if len(syms) == 0 {
+			return nil, errStripped
+		}
 		return nil, err
 	}
 	f.Gopclntab = b
+	f.addELFData(ef, syms)
 	return f, nil
 }
 
+// addELFData sets f's sections, and its variables, strings, itabs,
+// and types from ef's symbols syms.
+func (f *File) addELFData(ef *elf.File, syms []elf.Symbol) {
+	for _, s := range ef.Sections {
+		if s.Type == elf.SHT_NULL {
+			continue
+		}
+		ss := SectionSize{Name: s.Name, Addr: s.Addr, Size: int64(s.Size), FileSize: int64(s.FileSize)}
+		if s.Type == elf.SHT_NOBITS {
+			ss.FileSize = 0
+		}
+		f.Sections = append(f.Sections, ss)
+		switch s.Name {
+		case ".rodata", ".data", ".noptrdata", ".bss", ".noptrbss", ".typelink", ".go.type", ".data.rel.ro.go.type":
+			f.DataSections = append(f.DataSections, ss)
+		}
+	}
+	f.Vars = dwarfFileVars(ef.DWARF, ef.ByteOrder)
+	if f.Vars == nil {
+		f.Vars = elfDataSyms(ef, syms)
+	}
+	var allSyms []Sym
+	for _, sym := range syms {
+		if sym.Section != elf.SHN_UNDEF && sym.Section < elf.SHN_LORESERVE {
+			allSyms = append(allSyms, Sym{sym.Name, sym.Value, sym.Size})
+		}
+	}
+	f.Strings = f.goStrings(allSyms)
+	f.Itabs = f.goItabs(allSyms)
+	f.Types = f.dataSyms(allSyms, isTypeSym)
+}
+
+// elfDataSyms returns the sized data (STT_OBJECT) symbols in ef's
+// data sections.
+func elfDataSyms(ef *elf.File, syms []elf.Symbol) []Var {
+	var vars []Var
+	for _, sym := range syms {
+		if elf.ST_TYPE(sym.Info) != elf.STT_OBJECT || sym.Size == 0 || isStringSym(sym.Name) || isItabSym(sym.Name) || isTypeSym(sym.Name) ||
+			sym.Section >= elf.SHN_LORESERVE || int(sym.Section) >= len(ef.Sections) {
+			continue
+		}
+		switch ef.Sections[sym.Section].Name {
+		case ".rodata", ".data", ".noptrdata", ".bss", ".noptrbss", ".typelink", ".go.type", ".data.rel.ro.go.type":
+			vars = append(vars, Var{sym.Name, sym.Value, sym.Size})
+		}
+	}
+	return vars
+}
+
+// elfSymData returns the contents of ef from the address of the
+// symbol named start to that of the symbol named end.
+func elfSymData(ef *elf.File, syms []elf.Symbol, start, end string) ([]byte, error) {
+	var startAddr, endAddr uint64
+	for _, sym := range syms {
+		switch sym.Name {
+		case start:
+			startAddr = sym.Value
+		case end:
+			endAddr = sym.Value
+		}
+	}
+	if startAddr == 0 || endAddr < startAddr {
+		return nil, fmt.Errorf("no pclntab section or %s symbol found in ELF file", start)
+	}
+	for _, s := range ef.Sections {
+		if s.Type == elf.SHT_NOBITS || startAddr < s.Addr || endAddr > s.Addr+s.Size {
+			continue
+		}
+		b := make([]byte, endAddr-startAddr)
+		if _, err := s.ReadAt(b, int64(startAddr-s.Addr)); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("no ELF section contains %s", start)
+}
+
+// elfArch returns the GOARCH of ef, or else its machine name.
+func elfArch(ef *elf.File) string {
+	be := ef.ByteOrder == binary.BigEndian
+	is64 := ef.Class == elf.ELFCLASS64
+	switch ef.Machine {
+	case elf.EM_X86_64:
+		return "amd64"
+	case elf.EM_386:
+		return "386"
+	case elf.EM_AARCH64:
+		return "arm64"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_PPC64:
+		if be {
+			return "ppc64"
+		}
+		return "ppc64le"
+	case elf.EM_S390:
+		return "s390x"
+	case elf.EM_MIPS:
+		switch {
+		case is64 && be:
+			return "mips64"
+		case is64:
+			return "mips64le"
+		case be:
+			return "mips"
+		}
+		return "mipsle"
+	case elf.EM_RISCV:
+		return "riscv64"
+	case elf.EM_LOONGARCH:
+		return "loong64"
+	}
+	return ef.Machine.String()
+}
+
+// elfTextSyms returns the function symbols of syms in ef's .text
+// section.
+func elfTextSyms(ef *elf.File, syms []elf.Symbol) []Sym {
+	text := ef.Section(".text")
+	if text == nil {
+		return nil
+	}
+	var textSyms []Sym
+	for _, sym := range syms {
+		if elf.ST_TYPE(sym.Info) == elf.STT_FUNC && sym.Section < elf.SHN_LORESERVE &&
+			int(sym.Section) < len(ef.Sections) && ef.Sections[sym.Section] == text {
+			textSyms = append(textSyms, Sym{sym.Name, sym.Value, sym.Size})
+		}
+	}
+	return textSyms
+}
+
 func machoFile(mo *macho.File, ra io.ReaderAt, size int64) (*File, error) {
-	f := &File{Size: size}
+	// Gather symbols before the verbose logging below reorders
+	// mo.Sections, as symbols refer to sections by index.
+	f := &File{Size: size, Arch: machoArch(mo.Cpu), TextSyms: machoTextSyms(mo)}
 
 	if *verbose {
 		log.Printf("Got: %+v", mo.FileHeader)
@@ -136,8 +480,13 @@ func machoFile(mo *macho.File, ra io.ReaderAt, size int64) (*File, error) {
 		if *verbose {
 			log.Printf("sect[%d] = %+v\n", i, s.SectionHeader)
 		}
-		if s.Name == "__text" {
-			f.TextOffset = uint64(s.Offset)
+		if s.Name == "__text" && s.Seg == "__TEXT" {
+			// PCs are virtual addresses, not file offsets.
+			f.TextOffset = s.Addr
+			f.TextStart, f.TextEnd = s.Addr, s.Addr+s.Size
+		}
+		if s.Seg+","+s.Name == "__TEXT,__text" || s.Name == "__gopclntab" {
+			f.funcSections = append(f.funcSections, s.Seg+","+s.Name)
 		}
 		if s.Name == "__gopclntab" {
 			f.Gopclntab = make([]byte, s.Size)
@@ -146,18 +495,340 @@ func machoFile(mo *macho.File, ra io.ReaderAt, size int64) (*File, error) {
 				return nil, err
 			}
 		}
+		// Names aren't unique across segments; __rodata is in
+		// both __TEXT and __DATA_CONST.
+		ss := SectionSize{Name: s.Seg + "," + s.Name, Addr: s.Addr, Size: int64(s.Size), FileSize: int64(s.Size)}
+		const sectionType, zeroFill = 0xff, 0x1 // S_ZEROFILL
+		if s.Flags&sectionType == zeroFill {
+			ss.FileSize = 0
+		}
+		f.Sections = append(f.Sections, ss)
+		// All of __DATA_CONST is read-only data: type descriptors,
+		// the GOT, etc.
+		isData := s.Seg == "__DATA_CONST"
+		switch s.Name {
+		case "__rodata", "__noptrdata", "__data", "__bss", "__noptrbss", "__typelink", "__const":
+			isData = true
+		}
+		if isData {
+			f.DataSections = append(f.DataSections, ss)
+		}
 	}
+	f.Gaps = machoGaps(mo, size)
 	if f.Gopclntab == nil {
-		return nil, errors.New("no __gopclntab section found in macho file")
+		if mo.Symtab == nil || len(mo.Symtab.Syms) == 0 {
+			return nil, errStripped
+		}
+		// Like ELF, fall back to the pclntab's symbols.
+		b, err := machoSymData(mo, "runtime.pclntab", "runtime.epclntab")
+		if err != nil {
+			return nil, err
+		}
+		f.Gopclntab = b
+		for _, sym := range mo.Symtab.Syms {
+			if sym.Name != "runtime.pclntab" {
+				continue
+			}
+			// By address, as the verbose logging above reorders
+			// mo.Sections.
+			for _, s := range mo.Sections {
+				if sym.Value >= s.Addr && sym.Value < s.Addr+s.Size {
+					f.funcSections = append(f.funcSections, s.Seg+","+s.Name)
+				}
+			}
+		}
+	}
+	f.Vars = dwarfFileVars(mo.DWARF, mo.ByteOrder)
+	if mo.Symtab != nil {
+		var syms []Sym
+		for _, sym := range mo.Symtab.Syms {
+			if sym.Sect != 0 {
+				syms = append(syms, Sym{Name: sym.Name, Addr: sym.Value})
+			}
+		}
+		f.Strings = f.goStrings(syms)
+		f.Itabs = f.goItabs(syms)
+		f.Types = f.dataSyms(syms, isTypeSym)
 	}
 	return f, nil
 }
 
+// machoGaps returns the sizes of the parts of mo, a file of size
+// bytes, outside its sections: its header and load commands, the
+// bytes of each segment not in its sections, such as the symbol
+// table and code signature in __LINKEDIT, which has none, and the
+// padding between segments.
+func machoGaps(mo *macho.File, size int64) []SectionSize {
+	hdrSize := uint64(28) // fileHeaderSize32
+	if mo.Magic == macho.Magic64 {
+		hdrSize = 32 // fileHeaderSize64
+	}
+	type span struct{ start, end uint64 } // file offsets
+	covered := []span{{0, hdrSize + uint64(mo.Cmdsz)}}
+	gaps := []SectionSize{{Name: "header", FileSize: int64(covered[0].end)}}
+	var inSegments int64
+	for _, s := range mo.Sections {
+		const sectionType, zeroFill = 0xff, 0x1 // S_ZEROFILL
+		if s.Flags&sectionType != zeroFill {
+			covered = append(covered, span{uint64(s.Offset), uint64(s.Offset) + s.Size})
+		}
+	}
+	for _, l := range mo.Loads {
+		seg, ok := l.(*macho.Segment)
+		if !ok || seg.Filesz == 0 {
+			continue
+		}
+		inSegments += int64(seg.Filesz)
+		start, end := seg.Offset, seg.Offset+seg.Filesz
+		n := seg.Filesz
+		for _, c := range covered {
+			if lo, hi := max(c.start, start), min(c.end, end); lo < hi {
+				n -= hi - lo
+			}
+		}
+		if n > 0 {
+			gaps = append(gaps, SectionSize{Name: "segment:" + seg.Name, Addr: seg.Addr, FileSize: int64(n)})
+		}
+	}
+	if n := size - inSegments; n > 0 {
+		gaps = append(gaps, SectionSize{Name: "segment-alignment", FileSize: n})
+	}
+	return gaps
+}
+
+// machoSymData returns the contents of mo from the address of the
+// symbol named start to that of the symbol named end.
+func machoSymData(mo *macho.File, start, end string) ([]byte, error) {
+	var startAddr, endAddr uint64
+	for _, sym := range mo.Symtab.Syms {
+		switch sym.Name {
+		case start:
+			startAddr = sym.Value
+		case end:
+			endAddr = sym.Value
+		}
+	}
+	if startAddr == 0 || endAddr < startAddr {
+		return nil, fmt.Errorf("no __gopclntab section or %s symbol found in macho file", start)
+	}
+	for _, s := range mo.Sections {
+		const sectionType, zeroFill = 0xff, 0x1 // S_ZEROFILL
+		if s.Flags&sectionType == zeroFill || startAddr < s.Addr || endAddr > s.Addr+s.Size {
+			continue
+		}
+		b := make([]byte, endAddr-startAddr)
+		if _, err := s.ReadAt(b, int64(startAddr-s.Addr)); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("no macho section contains %s", start)
+}
+
+// machoFatFile opens the slice of a universal Mach-O binary for the
+// architecture named by the --arch flag, which is required if there's
+// more than one slice. Only that slice is parsed. With --arch=all,
+// slices that fail to parse are warned about and skipped.
+func machoFatFile(ff *macho.FatFile, ra io.ReaderAt) (*File, error) {
+	const what = "universal binary"
+	open := func(fa macho.FatArch) (*File, error) {
+		sr := io.NewSectionReader(ra, int64(fa.Offset), int64(fa.Size))
+		f, err := machoFile(fa.File, sr, int64(fa.Size))
+		if err != nil {
+			return nil, fmt.Errorf("%s slice: %w", machoArch(fa.Cpu), err)
+		}
+		return f, nil
+	}
+	if *arch == allArches {
+		var files []*File
+		for _, fa := range ff.Arches {
+			f, err := open(fa)
+			if err != nil {
+				warnf("%v", err)
+				continue
+			}
+			files = append(files, f)
+		}
+		if len(files) == 0 && len(ff.Arches) > 0 {
+			return nil, fmt.Errorf("no slice of %s could be opened", what)
+		}
+		return selectArch(what, files)
+	}
+	var arches []string
+	for _, fa := range ff.Arches {
+		arches = append(arches, machoArch(fa.Cpu))
+	}
+	i, err := archIndex(what, arches)
+	if err != nil {
+		return nil, err
+	}
+	return open(ff.Arches[i])
+}
+
+// allArches is the --arch flag value to analyze all of a file's
+// architectures.
+const allArches = "all"
+
+// selectArch returns the one of files, the per-architecture parts of
+// a file, with the architecture named by the --arch flag. If the flag
+// is empty, they must all have the same architecture. If it's "all",
+// the first is returned with the rest in its Others field. what
+// describes the file, for errors.
+func selectArch(what string, files []*File) (*File, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("empty %s", what)
+	}
+	if *arch == allArches {
+		f := files[0]
+		f.Others = files[1:]
+		return f, nil
+	}
+	var arches []string
+	for _, f := range files {
+		arches = append(arches, f.Arch)
+	}
+	i, err := archIndex(what, arches)
+	if err != nil {
+		return nil, err
+	}
+	return files[i], nil
+}
+
+// archIndex returns the index in arches, the architectures of a
+// file's parts, of the one named by the --arch flag, which mustn't be
+// "all". If the flag is empty, they must all be the same.
+func archIndex(what string, arches []string) (int, error) {
+	if len(arches) == 0 {
+		return 0, fmt.Errorf("empty %s", what)
+	}
+	for i, a := range arches {
+		if *arch == "" && a != arches[0] {
+			return 0, fmt.Errorf("%s has multiple architectures (%s); pick one with --arch", what, strings.Join(arches, ", "))
+		}
+		if *arch == a {
+			return i, nil
+		}
+	}
+	if *arch == "" {
+		return 0, nil
+	}
+	return 0, fmt.Errorf("no %s in %s; it has: %s", *arch, what, strings.Join(arches, ", "))
+}
+
+// machoArch returns the GOARCH of a Mach-O CPU type, or else its name.
+func machoArch(cpu macho.Cpu) string {
+	if name, ok := machoGOARCH[cpu]; ok {
+		return name
+	}
+	return cpu.String()
+}
+
+// machoGOARCH maps Mach-O CPU types to GOARCH values.
+var machoGOARCH = map[macho.Cpu]string{
+	macho.Cpu386:   "386",
+	macho.CpuAmd64: "amd64",
+	macho.CpuArm:   "arm",
+	macho.CpuArm64: "arm64",
+	macho.CpuPpc:   "ppc",
+	macho.CpuPpc64: "ppc64",
+}
+
+// pePclntabByMagic finds the pclntab of pf by looking for the magic
+// number and header of a Go 1.2+ pclntab at the start of a 4 byte
+// aligned word of a section. It returns the 0-based section number
+// and the pclntab's offsets in it.
+func pePclntabByMagic(pf *pe.File) (sect int, start, end int64, ok bool) {
+	for i, s := range pf.Sections {
+		if s.Characteristics&pe.IMAGE_SCN_CNT_CODE != 0 {
+			continue
+		}
+		b, err := s.Data()
+		if err != nil {
+			continue
+		}
+		for off := 0; off+16 <= len(b); off += 4 {
+			if !hasPclntab(b[off:]) || b[off+4] != 0 || b[off+5] != 0 {
+				continue
+			}
+			switch quantum, ptrSize := b[off+6], b[off+7]; {
+			case quantum != 1 && quantum != 2 && quantum != 4,
+				ptrSize != 4 && ptrSize != 8:
+				continue
+			}
+			size, err := gosym.NewLineTable(b[off:], 0).DataSize()
+			if err != nil || size == 0 {
+				continue
+			}
+			return i, int64(off), int64(off + size), true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// peGOARCH maps PE machine types to GOARCH values.
+var peGOARCH = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_I386:  "386",
+	pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+	pe.IMAGE_FILE_MACHINE_ARMNT: "arm",
+	pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+}
+
+// machoTextSyms returns the symbols in mo's __text section. Mach-O
+// symbols don't record their size, so each symbol is assumed to run
+// until the next one (or the end of the section).
+func machoTextSyms(mo *macho.File) []Sym {
+	if mo.Symtab == nil {
+		return nil
+	}
+	var text *macho.Section
+	var textSect uint8 // 1-based
+	for i, s := range mo.Sections {
+		if s.Name == "__text" && s.Seg == "__TEXT" {
+			text, textSect = s, uint8(i+1)
+			break
+		}
+	}
+	if text == nil {
+		return nil
+	}
+	var syms []Sym
+	for _, s := range mo.Symtab.Syms {
+		const nStab, nType, nSect = 0xe0, 0x0e, 0x0e
+		if s.Type&nStab != 0 || s.Type&nType != nSect || s.Sect != textSect {
+			continue
+		}
+		syms = append(syms, Sym{
+			Name: strings.TrimPrefix(s.Name, "_"),
+			Addr: s.Value,
+		})
+	}
+	sort.Slice(syms, func(i, j int) bool { return syms[i].Addr < syms[j].Addr })
+	end := text.Addr + text.Size
+	for i := range syms {
+		next := end
+		if i+1 < len(syms) {
+			next = syms[i+1].Addr
+		}
+		syms[i].Size = next - syms[i].Addr
+	}
+	return syms
+}
+
 func peFile(pf *pe.File, ra io.ReaderAt, size int64) (*File, error) {
-	f := &File{Size: size}
+	f := &File{Size: size, Arch: peGOARCH[pf.Machine]}
+	var imageBase uint64
+	switch oh := pf.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		imageBase = uint64(oh.ImageBase)
+	case *pe.OptionalHeader64:
+		imageBase = oh.ImageBase
+	}
 	for i, s := range pf.Sections {
 		if s.Name == ".text" {
-			f.TextOffset = uint64(s.Offset)
+			f.funcSections = append(f.funcSections, s.Name)
+			// PCs are virtual addresses, not file offsets.
+			f.TextOffset = imageBase + uint64(s.VirtualAddress)
+			f.TextStart, f.TextEnd = f.TextOffset, f.TextOffset+uint64(s.VirtualSize)
 		}
 		if *verbose {
 			log.Printf("sect[%d] = %+v", i, s.SectionHeader)
@@ -183,7 +854,16 @@ func peFile(pf *pe.File, ra io.ReaderAt, size int64) (*File, error) {
 		}
 	}
 	if start == 0 {
-		return nil, errors.New("didn't find runtime.pclntab symbol")
+		// Without the symbols (as with -ldflags=-s), look for the
+		// pclntab's header instead.
+		var ok bool
+		pclnSect, start, end, ok = pePclntabByMagic(pf)
+		if !ok {
+			if len(pf.Symbols) == 0 {
+				return nil, errStripped
+			}
+			return nil, errors.New("didn't find runtime.pclntab symbol")
+		}
 	}
 	if end == 0 {
 		return nil, errors.New("didn't find runtime.epclntab symbol")
@@ -202,191 +882,914 @@ func peFile(pf *pe.File, ra io.ReaderAt, size int64) (*File, error) {
 		return nil, err
 	}
 
+	for i, s := range pf.Sections {
+		ss := SectionSize{Name: s.Name, Addr: imageBase + uint64(s.VirtualAddress), Size: int64(s.VirtualSize), FileSize: int64(s.Size)}
+		f.Sections = append(f.Sections, ss)
+		switch s.Name {
+		case ".data", ".rdata", ".bss":
+		default:
+			continue
+		}
+		if ss.Size == 0 {
+			ss.Size = ss.FileSize
+		}
+		// Sections are padded in the file to the file alignment.
+		ss.FileSize = min(ss.FileSize, ss.Size)
+		if i == pclnSect {
+			// The pclntab is accounted for by function.
+			ss.Size -= pcLnSize
+			ss.FileSize -= pcLnSize
+		}
+		f.DataSections = append(f.DataSections, ss)
+	}
+	f.Vars = dwarfFileVars(pf.DWARF, binary.LittleEndian)
+	var syms []Sym
+	for _, sym := range pf.Symbols {
+		// COFF symbol values are offsets in their section.
+		if i := int(sym.SectionNumber) - 1; i >= 0 && i < len(pf.Sections) {
+			syms = append(syms, Sym{Name: sym.Name, Addr: imageBase + uint64(pf.Sections[i].VirtualAddress) + uint64(sym.Value)})
+		}
+	}
+	f.Strings = f.goStrings(syms)
+	f.Itabs = f.goItabs(syms)
+	f.Types = f.dataSyms(syms, isTypeSym)
+
 	return f, nil
 }
 
 func main() {
 	log.SetFlags(0)
 	flag.Parse()
+	parseColumns(*columns)
+	if *stream && (*validate || *dumpFuncs > 0) {
+		fatalf("--stream doesn't work with --validate or --dump-funcs")
+	}
 	if flag.NArg() != 1 {
-		log.Fatalf("Usage: shotizam <go-binary>")
+		fatalf("Usage: shotizam <go-binary | ->")
 	}
 	bin := flag.Arg(0)
 	if bin == "SELF" {
 		var err error
 		bin, err = os.Executable()
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 	}
 
-	of, err := os.Open(bin)
-	if err != nil {
-		log.Fatal(err)
+	ra, binSize, closeBin := openBinary(bin)
+	f, err := Open(ra, binSize)
+	if dbg := debugFilePath(bin, ra); dbg != "" {
+		f, err = withDebugFile(f, err, dbg, binSize)
 	}
-	fi, err := of.Stat()
+	closeBin()
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
-	binSize := fi.Size()
-	f, err := Open(of, binSize)
-	of.Close()
-	if err != nil {
-		log.Fatal(err)
+
+	// With --arch=all, files has each architecture's part of
+	// the binary, which are analyzed in turn, each row tagged
+	// with its architecture.
+	files := append([]*File{f}, f.Others...)
+	multiArch := len(files) > 1
+	if multiArch && (*validate || *dumpFuncs > 0) {
+		fatalf("--arch=all doesn't work with --validate or --dump-funcs")
 	}
 
-	lt := gosym.NewLineTable(f.Gopclntab, f.TextOffset)
-	t, err := gosym.NewTable(nil, lt)
-	if err != nil {
-		log.Fatal(err)
+	lt, t := openTable(f)
+	if *dumpFuncs > 0 {
+		fmt.Printf("%s pclntab, %d funcs\n", t.Version(), len(t.Funcs))
+		for i := 0; i < len(t.Funcs) && i < *dumpFuncs; i++ {
+			f := &t.Funcs[i]
+			fmt.Printf("func[%d] %s [%#x, %#x): %+v\n", i, f.Name, f.Entry, f.End, f.Raw())
+		}
+		return
 	}
 	// TODO: data
 
-	if *sqlite {
+	if *validate {
+		*sqlite = false
+	}
+	if *query != "" && !*sqlite {
+		fatalf("--query requires --sqlite")
+	}
+	if *sqlite && *sqldb != "" {
+		fatalf("--sqlite and --sqldb are mutually exclusive")
+	}
+	if *sqlite || *sqldb != "" {
 		*mode = "sql"
 	}
+	if *sqlViews && *sqldb != "" {
+		fatalf("--sql-views doesn't work with --sqldb")
+	}
+	if *sqlite && (*out != "" || *gzipOut) {
+		fatalf("--sqlite doesn't work with --out or --gzip")
+	}
+	if *sqldb != "" && (*out != "" || *gzipOut) {
+		fatalf("--sqldb doesn't work with --out or --gzip; it writes the database file itself")
+	}
+	if *serve != "" {
+		*mode = "serve"
+	}
+	if *pkgDiff != "" {
+		if *base == "" {
+			fatalf("--pkg-diff requires --base")
+		}
+		*mode = "json"
+	}
 
-	if *base != "" && *mode != "json" {
-		log.Fatalf("--base only works with json mode")
+	if *stream && *mode != "sql" && *mode != "tsv" && *mode != "modules" && *mode != "files" {
+		fatalf("--stream only works with sql, tsv, modules, and files modes")
+	}
+	if multiArch && *mode != "sql" && *mode != "tsv" && *mode != "json" && *mode != "yaml" && *mode != "modules" {
+		fatalf("--arch=all only works with sql, tsv, json, yaml, and modules modes")
+	}
+	if *base != "" && *mode != "json" && *mode != "yaml" && *mode != "modules" {
+		fatalf("--base only works with json, yaml, and modules modes")
 	}
 
 	var w io.WriteCloser = os.Stdout
+	if *validate {
+		w = nopWriteCloser()
+	}
 	switch *mode {
 	case "sql":
 	case "json":
+	case "yaml":
+	case "json-nested":
+	case "modules":
+	case "treemap-json":
+	case "pprof":
+	case "flamegraph":
+	case "treemap":
+	case "parquet":
+	case "bin-json":
+	case "markdown":
+	case "top":
+	case "trace":
+	case "tree":
+	case "prom":
+	case "serve":
+		if *serve == "" {
+			*serve = "localhost:8080"
+		}
+		if !strings.HasPrefix(*treemapLevels, "pkg") {
+			fatalf("serve mode requires --treemap-levels to start with pkg")
+		}
+		w = nopWriteCloser()
 	case "tsv":
-	case "nameinfo":
+	case "buildinfo":
+	case "sections":
+	case "files":
+	case "nameinfo", "abiwrappers":
 		w = nopWriteCloser()
 	default:
-		log.Fatalf("unknown mode %q", *mode)
+		fatalf("unknown mode %q", *mode)
 	}
 
+	// --sqlite pipes the sql mode's output into the sqlite3 binary's
+	// prompt if it's installed. Otherwise, and for --query, the Bin
+	// table is loaded in process with the SQLite driver instead.
+	var sqlBin string
+	if *sqlite && *query == "" {
+		sqlBin, _ = exec.LookPath("sqlite3")
+	}
+	sqlInProcess := *sqlite && sqlBin == ""
 	var cmd *exec.Cmd
-	var dbPath string
-	if *sqlite {
-		sqlBin, err := exec.LookPath("sqlite3")
-		if err != nil {
-			log.Fatalf("sqlite3 not found")
-		}
+	if sqlBin != "" {
 		td, err := os.MkdirTemp("", "shotizam")
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
-		dbPath = filepath.Join(td, "shotizam.db")
-		cmd = exec.Command(sqlBin, dbPath)
+		cmd = exec.Command(sqlBin, filepath.Join(td, "shotizam.db"))
 		w, err = cmd.StdinPipe()
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		if err := cmd.Start(); err != nil {
-			log.Fatal(err)
+			fatal(err)
+		}
+	}
+	if *out != "" && w == os.Stdout {
+		af, err := createAtomic(*out)
+		if err != nil {
+			fatal(err)
 		}
+		// Don't leave the temp file behind if we panic or exit.
+		defer af.abort()
+		atExit = append(atExit, af.abort)
+		w = af
+	}
+	// The pprof mode's output is already gzipped.
+	if (*gzipOut || strings.HasSuffix(*out, ".gz")) && !*sqlite && *mode != "pprof" {
+		w = newGzipWriteCloser(w)
 	}
 
+	var schema string  // of the sql mode's Bin table
+	var dbRows [][]any // for --sqldb, --sqlite without sqlite3, parquet, bin-json, and serve, the Bin table's rows
+	wantDBRows := *sqldb != "" || sqlInProcess || *mode == "parquet" || *mode == "bin-json" || *mode == "serve"
 	switch *mode {
 	case "sql":
+		archCol := ""
+		if multiArch {
+			archCol = ", Arch varchar"
+		}
+		schema = fmt.Sprintf("CREATE TABLE Bin (Func varchar, Pkg varchar, What varchar, Size int64%s%s)", archCol, sqlColumnDefs())
+		if *sqldb != "" || sqlInProcess {
+			w = nopWriteCloser()
+			break
+		}
 		fmt.Fprintln(w, "DROP TABLE IF EXISTS Bin;")
-		fmt.Fprintln(w, "CREATE TABLE Bin (Func varchar, Pkg varchar, What varchar, Size int64);")
+		fmt.Fprintf(w, "%s;\n", schema)
 		fmt.Fprintln(w, "BEGIN TRANSACTION;")
 	}
-	unaccountedSize := binSize
+	var unaccountedSize, totalSize int64
+	for _, f := range files {
+		totalSize += f.Size
+	}
+	unaccountedSize = totalSize
 
-	var names []string
 	var recs []Rec
+	var funcRecs []*FuncRec
+	mods := newModuleSizer(f.BuildInfo)
+	tree := newTreeBuilder(t, *treemapLevels)
+	prof := newPprofBuilder()
+	flame := newTreeBuilder(t, flameGraphLevels)
+	trace := newTreeBuilder(t, traceLevels)
+	pkgs := &pkgTree{}
+	pkgWhatSizes := map[RecKey]int64{} // by package and What, for markdown and prom modes
+	topSizes := map[string]int64{}     // by package, for top mode
+	abiWrappers := map[*gosym.Func]bool{}
+	if *mode == "abiwrappers" {
+		for _, fn := range t.ABIWrappers() {
+			abiWrappers[fn] = true
+		}
+	}
+	abiWhat := map[string]int64{}      // What => total bytes of ABI wrappers
+	funcRecOf := map[RecKey]*FuncRec{} // keyed by name & package, without What
+
+	var recArch string // architecture of the file being emitted, if multiArch
+	sqlBatchRows := 0  // rows in the sql mode's current INSERT statement
 
-	for i := range t.Funcs {
-		f := &t.Funcs[i]
-		names = append(names, f.Name)
-		emit := func(what string, size int64) {
-			unaccountedSize -= size
-			if size == 0 {
-				return
-			}
-			switch *mode {
-			case "sql":
-				// TODO: include truncated name, stopping at first ".func" closure.
-				// Likewise, add field for func truncated just past type too. ("Type"?)
-				fmt.Fprintf(w, "INSERT INTO Bin VALUES (%s, %s, %s, %v);\n",
-					sqlString(f.Name),
-					sqlString(f.PackageName()),
-					sqlString(what),
-					size)
-			case "tsv":
-				fmt.Fprintf(w, "%s\t%s\t%s\t%v\n", f.Name, f.PackageName(), what, size)
-			case "json":
-				recs = append(recs, Rec{RecKey{f.Name, f.PackageName(), what}, size})
-			}
-		}
-		emit("fixedheader", int64(t.PtrSize()+8*4))        // uintptr + 8 x int32s in _func
-		emit("funcdata", int64(t.PtrSize()*f.NumFuncData)) // TODO: add optional 4 byte alignment padding before first funcdata
-		emit("pcsp", int64(f.TableSizePCSP()))
-		emit("pcfile", int64(f.TableSizePCFile()))
-		emit("pcln", int64(f.TableSizePCLn()))
-		for tab := 0; tab < f.NumPCData; tab++ {
-			emit(fmt.Sprintf("pcdata%d%s", tab, pcdataSuffix(tab)), int64(4 /* offset pointer */ +f.TableSizePCData(tab)))
-		}
-		// TODO: the other funcdata and pcdata tables
-		emit("text", int64(f.End-f.Entry))
-		emit("funcname", int64(len(f.Name)+len("\x00")))
+	// emitRec emits a record of size bytes. fi is the function the
+	// bytes belong to, or nil if they're not for a function.
+	emitRec := func(fi *funcInfo, name, pkg, what string, size int64) {
+		unaccountedSize -= size
+		if size == 0 {
+			return
+		}
+		var fn *gosym.Func
+		if fi != nil {
+			fn = fi.fn
+		}
+		if wantDBRows {
+			row := []any{name, pkg, what, size}
+			if multiArch {
+				row = append(row, recArch)
+			}
+			dbRows = append(dbRows, append(row, fi.cols()...))
+		}
+		switch *mode {
+		case "sql":
+			if *sqldb != "" || sqlInProcess {
+				break
+			}
+			if sqlBatchRows == 0 {
+				fmt.Fprint(w, "INSERT INTO Bin VALUES\n")
+			} else {
+				fmt.Fprint(w, ",\n")
+			}
+			fmt.Fprintf(w, "(%s, %s, %s, %v",
+				sqlString(name),
+				sqlString(pkg),
+				sqlString(what),
+				size)
+			if multiArch {
+				fmt.Fprintf(w, ", %s", sqlString(recArch))
+			}
+			for _, v := range fi.cols() {
+				fmt.Fprintf(w, ", %s", sqlValue(v))
+			}
+			fmt.Fprint(w, ")")
+			if sqlBatchRows++; sqlBatchRows == sqlBatchSize {
+				fmt.Fprint(w, ";\n")
+				sqlBatchRows = 0
+			}
+		case "tsv":
+			fmt.Fprintf(w, "%s\t%s\t%s\t%v", name, pkg, what, size)
+			if multiArch {
+				fmt.Fprintf(w, "\t%s", recArch)
+			}
+			for _, v := range fi.cols() {
+				fmt.Fprintf(w, "\t%s", tsvValue(v))
+			}
+			fmt.Fprintf(w, "\n")
+		case "json", "yaml":
+			recs = append(recs, Rec{RecKey: RecKey{Name: name, Package: pkg, What: what, Arch: recArch}, Size: size, Cols: fi.recCols()})
+		case "json-nested":
+			k := RecKey{Name: name, Package: pkg}
+			fr := funcRecOf[k]
+			if fr == nil {
+				fr = &FuncRec{Name: name, Package: pkg, What: map[string]int64{}}
+				if fn != nil {
+					fr.File, _, _ = t.PCToLine(fn.Entry)
+				}
+				funcRecOf[k] = fr
+				funcRecs = append(funcRecs, fr)
+			}
+			fr.Size += size
+			fr.What[what] += size
+		case "modules":
+			mods.add(pkg, size)
+		case "markdown", "prom":
+			pkgWhatSizes[RecKey{Package: pkg, What: what}] += size
+		case "top":
+			topSizes[pkg] += size
+		case "treemap-json":
+			tree.add(fn, name, pkg, what, size)
+		case "treemap", "flamegraph", "serve":
+			// Negative sizes, like not-in-file, can't be drawn.
+			if size <= 0 {
+				break
+			}
+			if *mode != "flamegraph" {
+				tree.add(fn, name, pkg, what, size)
+			}
+			if *mode != "treemap" {
+				if name == "" {
+					name = what
+				}
+				flame.add(fn, name, pkg, what, size)
+			}
+		case "pprof":
+			prof.add(fi, name, pkg, what, size)
+		case "tree":
+			pkgs.add(fn, name, pkg, what, size)
+		case "trace":
+			// Durations can't be negative, like not-in-file.
+			if size <= 0 {
+				break
+			}
+			if name == "" {
+				name, what = what, ""
+			}
+			trace.add(fn, name, pkg, what, size)
+		case "abiwrappers":
+			if abiWrappers[fn] {
+				abiWhat[what] += size
+			}
+		}
 	}
 
+	for i, f := range files {
+		if i > 0 {
+			lt, t = openTable(f)
+		}
+		if multiArch {
+			recArch = f.Arch
+		}
+		textSize := f.funcTextSize
+		// The bytes of the funcnametab and pctab attributed to
+		// functions, to not count them again.
+		var funcNameBytes, pctabBytes int64
+		// Funcdata shared by several functions are attributed to
+		// the first.
+		seenFuncData := map[uint32]bool{}
+		// With --inline, the text inlined into all functions, by
+		// inlined function, emitted after the functions so each
+		// has one row.
+		inlinedSize := map[string]int64{}
+		var goVersion string
+		if f.BuildInfo != nil {
+			goVersion = f.BuildInfo.GoVersion
+		}
+		emitFunc := func(f *gosym.Func) {
+			// Gather all the function's sizes before emitting any,
+			// as some columns depend on them all.
+			fi := &funcInfo{t: t, fn: f, goVersion: goVersion}
+			emit := func(what string, size int64) {
+				fi.sizes = append(fi.sizes, whatSize{what, size})
+			}
+			emit("fixedheader", int64(t.FuncHeaderSize()))
+			if pad := f.FuncDataPadding(); pad > 0 {
+				emit("padding", int64(pad))
+			}
+			emit("funcdata", int64(f.FuncDataSize()))
+			for i, size := range f.FuncDataSizes() {
+				if off := f.FuncDataOffsets[i]; size > 0 && !seenFuncData[off] {
+					seenFuncData[off] = true
+					if i == gosym.FuncDataStackObjects {
+						// The stack objects table, less any padding.
+						stkobj := f.StackObjectsSize()
+						emit("stkobj", int64(stkobj))
+						size -= stkobj
+					}
+					emit(fmt.Sprintf("funcdata%d%s", i, funcdataSuffix(i)), int64(size))
+				}
+			}
+			emit("pcsp", int64(f.TableSizePCSP()))
+			emit("pcfile", int64(f.TableSizePCFile()))
+			emit("pcln", int64(f.TableSizePCLn()))
+			pctabBytes += int64(f.TableSizePCSP() + f.TableSizePCFile() + f.TableSizePCLn())
+			for tab := 0; tab < f.NumPCData; tab++ {
+				emit(fmt.Sprintf("pcdata%d%s", tab, pcdataSuffix(f.LineTable, tab)), int64(4 /* offset pointer */ +f.TableSizePCData(tab)))
+				pctabBytes += int64(f.TableSizePCData(tab))
+			}
+			text := textSize(f)
+			inlined, inlinedText := inlinedCalls(f, text)
+			if f.Name == "go:buildid" {
+				// Not code, but the build ID of a non-ELF
+				// binary, which ELF binaries have in a
+				// .note.go.buildid section instead.
+				emit("buildid", text)
+			} else {
+				emit("text", text-inlinedText)
+			}
+			emit("funcname", int64(len(f.Name)+len("\x00")))
+			funcNameBytes += int64(len(f.Name) + len("\x00"))
+			for _, ws := range fi.sizes {
+				emitRec(fi, f.Name, f.PackageName(), ws.what, ws.size)
+			}
+			for name, size := range inlined {
+				inlinedSize[name] += size
+			}
+		}
+		if *stream {
+			it := lt.FuncIter()
+			for it.Next() {
+				emitFunc(it.Func())
+			}
+			if err := it.Err(); err != nil {
+				fatal(err)
+			}
+		} else {
+			for i := range t.Funcs {
+				emitFunc(&t.Funcs[i])
+			}
+		}
+		for _, name := range sortedKeys(inlinedSize) {
+			emitRec(nil, name, (&gosym.Sym{Name: name}).PackageName(), "text", inlinedSize[name])
+		}
+
+		// The rest of the pclntab. What's left of the funcnametab
+		// is mostly the names of inlined functions. What's left of
+		// the pctab may be negative, as the linker deduplicates
+		// tables shared by several functions.
+		if regions := lt.RegionSizes(); regions != nil {
+			if _, ok := regions["funcnametab"]; ok {
+				regions["funcnametab"] -= int(funcNameBytes)
+				regions["pctab"] -= int(pctabBytes)
+			}
+			for _, name := range sortedKeys(regions) {
+				emitRec(nil, "", "", "pclntab:"+name, int64(regions[name]))
+			}
+		}
+
+		// Text symbols not covered by the pclntab are C (or other
+		// non-Go) code, as linked into cgo binaries.
+		var cText int64
+		for _, s := range f.TextSyms {
+			if s.Size == 0 || t.PCToFunc(s.Addr) != nil {
+				continue
+			}
+			if *cSyms {
+				emitRec(nil, s.Name, "", "ctext", int64(s.Size))
+			} else {
+				cText += int64(s.Size)
+			}
+		}
+		emitRec(nil, "", "", "ctext", cText)
+
+		varPkgSize, varSectionSize := f.varSizes()
+		for _, pkg := range sortedKeys(varPkgSize) {
+			emitRec(nil, "", pkg, "var", varPkgSize[pkg])
+		}
+		strPkgSize, strSectionSize := f.dataSizes(f.Strings)
+		for _, pkg := range sortedKeys(strPkgSize) {
+			emitRec(nil, "", pkg, "strings", strPkgSize[pkg])
+		}
+		for _, it := range f.Itabs {
+			emitRec(nil, it.Name, itabPackage(it.Name), "itab", int64(it.Size))
+		}
+		_, itabSectionSize := f.dataSizes(f.Itabs)
+		typePkgSize := map[string]int64{}
+		for _, typ := range f.Types {
+			typePkgSize[typePackage(typ.Name)] += int64(typ.Size)
+		}
+		for _, pkg := range sortedKeys(typePkgSize) {
+			emitRec(nil, "", pkg, "rtti", typePkgSize[pkg])
+		}
+		_, typeSectionSize := f.dataSizes(f.Types)
+		var notInFile int64
+		for _, s := range f.DataSections {
+			// The variables, strings, itabs, and types found in
+			// the section are accounted for above.
+			size := s.Size - varSectionSize[s.Name] - strSectionSize[s.Name] - itabSectionSize[s.Name] - typeSectionSize[s.Name]
+			emitRec(nil, "", "", "section:"+s.Name, size)
+			notInFile += s.Size - s.FileSize
+		}
+		for _, s := range f.otherSections() {
+			if s.Name == ".note.go.buildid" {
+				emitRec(nil, "", "", "buildid", s.FileSize)
+				continue
+			}
+			if name, ok := dwarfSectionName(s.Name); ok {
+				emitRec(nil, "", "", "dwarf:"+name, s.FileSize)
+				continue
+			}
+			emitRec(nil, "", "", "section:"+s.Name, s.FileSize)
+		}
+		for _, g := range f.Gaps {
+			emitRec(nil, "", "", g.Name, g.FileSize)
+		}
+		// Only the part of the data sections in the file counts
+		// against the file's size. Subtracting the rest keeps
+		// SUM(Size) equal to the file's size.
+		emitRec(nil, "", "", "not-in-file", -notInFile)
+	}
+
+	// Every byte of the file should be in some row. A lot left
+	// over, or a negative amount from counting some twice, means
+	// something's missing or wrong.
+	if pct := float64(unaccountedSize) * 100 / float64(totalSize); *maxUnaccounted > 0 && math.Abs(pct) > *maxUnaccounted {
+		warnf("%s: %d of %d bytes (%.1f%%) unaccounted for", bin, unaccountedSize, totalSize, pct)
+	}
+
+	if *validate {
+		if err := t.Validate(); err != nil {
+			fmt.Printf("FAIL: %s: %s pclntab: %v\n", bin, t.Version(), err)
+			exit(1)
+		}
+		fmt.Printf("PASS: %s: %s pclntab, %d funcs, %d of %d bytes (%.1f%%) unaccounted\n",
+			bin, t.Version(), len(t.Funcs), unaccountedSize, f.Size, float64(unaccountedSize)*100/float64(f.Size))
+		return
+	}
+
+	if wantDBRows {
+		row := make([]any, len(binColumns(multiArch)))
+		row[2], row[3] = "TODO", unaccountedSize
+		dbRows = append(dbRows, row)
+	}
 	switch *mode {
+	case "parquet":
+		if err := writeParquet(w, binColumns(multiArch), dbRows); err != nil {
+			fatal(err)
+		}
+	case "bin-json":
+		if err := writeBinJSON(w, binColumns(multiArch), dbRows); err != nil {
+			fatal(err)
+		}
 	case "sql":
+		if *sqldb != "" {
+			if err := writeSQLDB(*sqldb, schema, dbRows); err != nil {
+				fatal(err)
+			}
+			break
+		}
+		if sqlInProcess {
+			runSQLite(schema, dbRows, multiArch)
+			return
+		}
+		if sqlBatchRows > 0 {
+			fmt.Fprint(w, ";\n")
+		}
 		fmt.Fprintf(w, "INSERT INTO Bin (What, Size) VALUES ('TODO', %v);\n", unaccountedSize)
+		if *sqlViews {
+			// Indexing after the inserts is faster than before.
+			fmt.Fprint(w, sqlIndexesAndViews(multiArch))
+		}
 		fmt.Fprintln(w, "END TRANSACTION;")
 	case "json":
+		if *pkgDiff != "" {
+			writePkgDiff(w, *pkgDiff, PkgDiff(readBaseRecs(), recs, *pkgDiff))
+			break
+		}
 		if *base != "" {
-			old := readBaseRecs()
-			oldm := recMap(old)
-			newm := recMap(recs)
-			recs = diffMap(oldm, newm)
+			recs = sizes.Diff(readBaseRecs(), recs)
 		}
 		je := json.NewEncoder(w)
 		je.SetIndent("", "\t")
 		if err := je.Encode(recs); err != nil {
-			log.Fatal(err)
+			fatal(err)
+		}
+	case "yaml":
+		if *base != "" {
+			recs = sizes.Diff(readBaseRecs(), recs)
+		}
+		if err := writeYAML(w, recs); err != nil {
+			fatal(err)
+		}
+	case "markdown":
+		writeMarkdown(w, pkgWhatSizes, *top)
+	case "prom":
+		if unaccountedSize != 0 {
+			pkgWhatSizes[RecKey{What: "TODO"}] += unaccountedSize
+		}
+		writeProm(w, filepath.Base(bin), pkgWhatSizes, totalSize)
+	case "top":
+		if unaccountedSize != 0 {
+			topSizes["(unaccounted)"] += unaccountedSize
+		}
+		writeTop(w, topSizes, totalSize, *top, w == os.Stdout && !*gzipOut && isTerminal(os.Stdout))
+	case "modules":
+		modRecs := mods.recs()
+		if *base != "" {
+			modRecs = DiffModules(readBaseModuleRecs(), modRecs)
+		}
+		je := json.NewEncoder(w)
+		je.SetIndent("", "\t")
+		if err := je.Encode(modRecs); err != nil {
+			fatal(err)
+		}
+	case "pprof":
+		prof.add(nil, "", "", "TODO", unaccountedSize)
+		if err := prof.write(w); err != nil {
+			fatal(err)
+		}
+	case "flamegraph":
+		flame.root.Name = filepath.Base(bin)
+		if err := writeFlameGraph(w, filepath.Base(bin), flame.root); err != nil {
+			fatal(err)
+		}
+	case "treemap":
+		tree.root.Name = filepath.Base(bin)
+		if err := writeTreemap(w, filepath.Base(bin), tree.root); err != nil {
+			fatal(err)
+		}
+	case "serve":
+		tree.root.Name = filepath.Base(bin)
+		flame.root.Name = filepath.Base(bin)
+		log.Printf("serving %s on http://%s/", bin, *serve)
+		ts, err := newTreeServer(filepath.Base(bin), tree.root, flame.root, binColumns(multiArch), dbRows)
+		if err != nil {
+			fatal(err)
+		}
+		fatal(http.ListenAndServe(*serve, ts))
+	case "tree":
+		pkgs.name = filepath.Base(bin)
+		if unaccountedSize != 0 {
+			pkgs.add(nil, "", "", "TODO", unaccountedSize)
+		}
+		writeTree(w, pkgs, *top)
+	case "trace":
+		trace.root.Name = filepath.Base(bin)
+		if err := writeTrace(w, filepath.Base(bin), trace.root); err != nil {
+			fatal(err)
+		}
+	case "treemap-json":
+		tree.root.sortBySize()
+		je := json.NewEncoder(w)
+		je.SetEscapeHTML(false)
+		if err := je.Encode(tree.root); err != nil {
+			fatal(err)
+		}
+	case "json-nested":
+		je := json.NewEncoder(w)
+		je.SetIndent("", "\t")
+		if err := je.Encode(funcRecs); err != nil {
+			fatal(err)
+		}
+	case "buildinfo":
+		if f.BuildInfo == nil && f.BuildID == "" {
+			fatalf("%s has no build info", bin)
+		}
+		if f.BuildInfo != nil {
+			fmt.Fprint(w, f.BuildInfo)
+		}
+		if f.BuildID != "" {
+			fmt.Fprintf(w, "buildid\t%s\n", f.BuildID)
+		}
+	case "sections":
+		writeSections(w, f)
+	case "files":
+		if err := writeFiles(w, f, t); err != nil {
+			fatal(err)
 		}
 	case "nameinfo":
-		sort.Strings(names)
-		var totNames, skip int
-		for i, name := range names {
-			totNames += len(name)
-			var next string
-			if i < len(names)-1 {
-				next = names[i+1]
-			}
-			if strings.HasPrefix(next, name) {
-				skip += len(name)
-			}
+		total, unique, prefixShared := t.FuncNameStats()
+		log.Printf("                          total length of func names: %d", total)
+		log.Printf("                         length of unique func names: %d", unique)
+		log.Printf("bytes of func names which are prefixes of other func: %d", prefixShared)
+		return
+	case "abiwrappers":
+		var whats []string
+		var tot int64
+		for what, size := range abiWhat {
+			whats = append(whats, what)
+			tot += size
+		}
+		sort.Slice(whats, func(i, j int) bool { return abiWhat[whats[i]] > abiWhat[whats[j]] })
+		log.Printf("%d ABI wrappers, %d bytes (%.2f%% of file)", len(abiWrappers), tot, float64(tot)*100/float64(f.Size))
+		for _, what := range whats {
+			log.Printf("%20s: %d", what, abiWhat[what])
 		}
-		log.Printf("                          total length of func names: %d", totNames)
-		log.Printf("bytes of func names which are prefixes of other func: %d", skip)
 		return
 	}
 
-	w.Close()
+	if err := w.Close(); err != nil {
+		fatal(err)
+	}
 	if cmd != nil {
 		if err := cmd.Wait(); err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		if err := syscall.Exec(cmd.Path, cmd.Args, cmd.Env); err != nil {
-			log.Fatal(err)
+			fatal(err)
+		}
+	}
+}
+
+// runSQLite loads the Bin table's schema and rows into an in-memory
+// SQLite database and runs the --query query in it, or else prompts
+// for queries, for --sqlite without the sqlite3 binary.
+func runSQLite(schema string, rows [][]any, multiArch bool) {
+	db, err := loadSQLDB(":memory:", schema, rows)
+	if err != nil {
+		fatal(err)
+	}
+	defer db.Close()
+	if *sqlViews {
+		if _, err := db.Exec(sqlIndexesAndViews(multiArch)); err != nil {
+			fatal(err)
+		}
+	}
+	if *query != "" {
+		err = runSQL(os.Stdout, db, *query)
+	} else {
+		err = sqlPrompt(os.Stdout, os.Stdin, db)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// inlinedCalls returns, if the --inline flag is set, the sizes of
+// the code inlined into f by inlined function name, and their total.
+// It returns nothing if the inline tree accounts for more than f's
+// text bytes, as then its PCs aren't what they seem.
+func inlinedCalls(f *gosym.Func, text int64) (sizes map[string]int64, total int64) {
+	if !*inline {
+		return nil, 0
+	}
+	sizes = f.InlinedText()
+	for _, n := range sizes {
+		total += n
+	}
+	if total > text {
+		return nil, 0
+	}
+	return sizes, total
+}
+
+// sortedKeys returns the keys of m, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// openBinary opens the binary named on the command line, which is
+// read from stdin if it's "-". The returned func closes it.
+func openBinary(bin string) (ra io.ReaderAt, size int64, close func()) {
+	if bin == "-" {
+		// Open needs random access, so read it all.
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fatal(err)
 		}
+		return bytes.NewReader(b), int64(len(b)), func() {}
+	}
+	of, err := os.Open(bin)
+	if err != nil {
+		fatal(err)
+	}
+	fi, err := of.Stat()
+	if err != nil {
+		fatal(err)
+	}
+	return of, fi.Size(), func() { of.Close() }
+}
+
+// openTable returns f's pclntab and symbol table.
+func openTable(f *File) (*gosym.LineTable, *gosym.Table) {
+	lt := gosym.NewLineTable(f.Gopclntab, f.TextOffset)
+	var t *gosym.Table
+	var err error
+	if *stream {
+		t, err = gosym.NewLazyTable(lt)
+	} else {
+		t, err = gosym.NewTable(nil, lt)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if err := checkTextOffset(t, f); err != nil {
+		warnf("%v", err)
+	}
+	return lt, t
+}
+
+// warnf logs a warning, or exits if the --strict flag is set.
+func warnf(format string, args ...any) {
+	if *strict {
+		fatalf("error: "+format, args...)
+	}
+	log.Printf("warning: "+format, args...)
+}
+
+// checkTextOffset sanity checks f.TextOffset against the entry PC
+// of the first function in t. The first function should start at
+// or shortly after the start of the text section, and certainly
+// within the size of the file. If it doesn't, the text offset is
+// probably wrong (e.g. a file offset was used where a virtual address
+// was needed) and the function sizes can't be trusted. Since Go 1.18,
+// entry PCs are offsets from the text offset, so they're checked
+// against f.TextStart, found independently of it, if known. Likewise
+// the last function must end within the file, or else the entry PCs
+// weren't all relative to the same text start (as on PIE binaries,
+// where the pclntab's own textStart is unrelocated) and the sizes
+// would be garbage.
+func checkTextOffset(t *gosym.Table, f *File) error {
+	first, last := t.EndFuncs()
+	if first == nil {
+		return nil
+	}
+	entry := first.Entry
+	start := f.TextStart
+	if start == 0 {
+		start = f.TextOffset
 	}
+	if entry < start || entry-start > uint64(f.Size) {
+		return fmt.Errorf("first function %q at %#x is implausibly far from the text start %#x; text offset %#x may be wrong", first.Name, entry, start, f.TextOffset)
+	}
+	if last.End < entry || last.End-entry > uint64(f.Size) {
+		return fmt.Errorf("last function %q ends at %#x, implausibly far from the first function at %#x; function sizes can't be trusted", last.Name, last.End, entry)
+	}
+	return nil
+}
+
+// pcdataSuffix returns a description of t's pcdata index n, if known.
+func pcdataSuffix(t *gosym.LineTable, n int) string {
+	if name := t.PCDataName(n); name != "" {
+		return "-" + name
+	}
+	return ""
 }
 
-func pcdataSuffix(n int) string {
+// funcdataSuffix returns a description of funcdata index n, as in
+// the FUNCDATA_* constants in src/internal/abi/symtab.go.
+func funcdataSuffix(n int) string {
 	switch n {
 	case 0:
-		return "-unsafepoint"
+		return "-argsptrmaps"
 	case 1:
-		return "-stackmap"
-	case 2:
+		return "-localsptrmaps"
+	case gosym.FuncDataStackObjects:
+		return "-stackobjects"
+	case 3:
 		return "-inltree"
+	case 4:
+		return "-opendefer"
+	case 5:
+		return "-arginfo"
+	case 6:
+		return "-arglive"
+	case 7:
+		return "-wrapinfo"
 	}
 	return ""
 }
 
+// sqlIndexesAndViews returns the SQL statements with which --sql-views
+// indexes the Bin table and creates views of its common aggregates.
+// With multiArch, the views also group by Arch.
+func sqlIndexesAndViews(multiArch bool) string {
+	arch := ""
+	if multiArch {
+		arch = "Arch, "
+	}
+	var sb strings.Builder
+	sb.WriteString("CREATE INDEX IF NOT EXISTS BinPkg ON Bin (Pkg);\n")
+	sb.WriteString("CREATE INDEX IF NOT EXISTS BinWhat ON Bin (What);\n")
+	for _, v := range []struct{ name, cols, where string }{
+		{"PkgTotals", "Pkg", ""},
+		{"WhatTotals", "What", ""},
+		{"FuncTotals", "Func, Pkg", " WHERE Func <> ''"},
+	} {
+		fmt.Fprintf(&sb, "DROP VIEW IF EXISTS %s;\n", v.name)
+		fmt.Fprintf(&sb, "CREATE VIEW %s AS SELECT %s%s, SUM(Size) AS Size FROM Bin%s GROUP BY %s%s ORDER BY Size DESC;\n",
+			v.name, arch, v.cols, v.where, arch, v.cols)
+	}
+	return sb.String()
+}
+
+// sqlBatchSize is the number of rows the sql mode inserts per INSERT
+// statement. Inserting many rows at once makes the output smaller and
+// much faster for sqlite3 to load than an INSERT per row.
+const sqlBatchSize = 500
+
 func sqlString(s string) string {
 	var sb strings.Builder
 	sb.WriteByte('\'')
@@ -412,60 +1815,44 @@ func nopWriteCloser() io.WriteCloser {
 }
 
 func readBaseRecs() []Rec {
-	f, err := os.Open(*base)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer f.Close()
 	var recs []Rec
-	if err := json.NewDecoder(f).Decode(&recs); err != nil {
-		log.Fatal(err)
-	}
+	decodeBase(&recs)
 	return recs
 }
 
-type RecKey struct {
-	Name    string `json:"name,omitempty"`
-	Package string `json:"package,omitempty"`
-	What    string `json:"what"`
-}
-
-type Rec struct {
-	RecKey
-	Size int64 `json:"size"`
-}
-
-func recMap(recs []Rec) map[RecKey]int64 {
-	m := make(map[RecKey]int64)
-	for _, r := range recs {
-		m[r.RecKey] = r.Size
+// decodeBase decodes the JSON of the --base file into v. The file
+// may be gzipped, as by --gzip.
+func decodeBase(v any) {
+	f, err := os.Open(*base)
+	if err != nil {
+		fatal(err)
 	}
-	return m
-}
-
-func diffMap(a, b map[RecKey]int64) []Rec {
-	diff := make(map[RecKey]int64)
-	for k, size := range b {
-		oldSize, ok := a[k]
-		change := size - oldSize
-		if change != 0 {
-			diff[k] = change
-		}
-		if ok {
-			delete(a, k)
+	defer f.Close()
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); string(magic) == "\x1f\x8b" {
+		if r, err = gzip.NewReader(br); err != nil {
+			fatal(err)
 		}
 	}
-	// Anything not deleted in a is stuff we dropped. Count it as
-	// negative size.
-	for k, size := range a {
-		diff[k] = -size
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		fatal(err)
 	}
+}
 
-	recs := make([]Rec, 0, len(diff))
-	for k, size := range diff {
-		recs = append(recs, Rec{k, size})
-	}
-	sort.Slice(recs, func(i, j int) bool { return recs[i].Size < recs[j].Size })
+// Rec and RecKey are the json mode's output records, which
+// --base diffs with sizes.Diff.
+type (
+	Rec    = sizes.Rec
+	RecKey = sizes.RecKey
+)
 
-	return recs
+// FuncRec is the json-nested output record, with the sizes of all of
+// a function's What categories grouped together.
+type FuncRec struct {
+	Name    string           `json:"name,omitempty"`
+	Package string           `json:"package,omitempty"`
+	File    string           `json:"file,omitempty"`
+	Size    int64            `json:"size"`
+	What    map[string]int64 `json:"what"`
 }
@@ -0,0 +1,2211 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"debug/buildinfo"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bradfitz/shotizam/gosym"
+	"github.com/bradfitz/shotizam/sizes"
+	"github.com/parquet-go/parquet-go"
+)
+
+// TestMain runs the shotizam command instead of the tests if
+// runShotizamEnv is set, for tests of the command's output.
+func TestMain(m *testing.M) {
+	if os.Getenv(runShotizamEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+const runShotizamEnv = "SHOTIZAM_TEST_RUN_MAIN"
+
+// runShotizam runs the shotizam command with args, returning its
+// standard output and error.
+func runShotizam(t *testing.T, args ...string) (stdout, stderr []byte) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), runShotizamEnv+"=1")
+	var errBuf bytes.Buffer
+	cmd.Stderr = &errBuf
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("shotizam %s: %v\n%s", strings.Join(args, " "), err, errBuf.Bytes())
+	}
+	return out, errBuf.Bytes()
+}
+
+func TestMetaRatio(t *testing.T) {
+	fi := &funcInfo{sizes: []whatSize{{"fixedheader", 40}, {"pcsp", 10}, {"text", 100}}}
+	if got, want := metaRatio(fi), 0.5; got != want {
+		t.Errorf("metaRatio = %v; want %v", got, want)
+	}
+	fi = &funcInfo{sizes: []whatSize{{"fixedheader", 40}}}
+	if got := metaRatio(fi); got != nil {
+		t.Errorf("metaRatio with no text = %v; want nil", got)
+	}
+}
+
+func TestFuncTrunc(t *testing.T) {
+	for name, want := range map[string]string{
+		"main.main":                     "main.main",
+		"pkg.Foo.func1":                 "pkg.Foo",
+		"pkg.Foo.func1.2":               "pkg.Foo",
+		"pkg.(*T).Bar.func3":            "pkg.(*T).Bar",
+		"pkg.glob..func1":               "pkg.glob",
+		"reflect.funcLayout":            "reflect.funcLayout",
+		"reflect.funcLayout.func1":      "reflect.funcLayout",
+		"pkg.F[go.shape.int].func2":     "pkg.F[go.shape.int]",
+		"example.com/a.func/b.F.func10": "example.com/a.func/b.F",
+		"pkg.Foo.func":                  "pkg.Foo.func",
+	} {
+		if got := funcTrunc(name); got != want {
+			t.Errorf("funcTrunc(%q) = %q; want %q", name, got, want)
+		}
+	}
+}
+
+func TestFuncType(t *testing.T) {
+	for name, want := range map[string]any{
+		"main.main":                            nil,
+		"go:buildid":                           nil,
+		"pkg.Foo.func1":                        nil,
+		"net/http.(*Server).Serve":             "net/http.(*Server)",
+		"net/http.(*Server).Serve.func2.1":     "net/http.(*Server)",
+		"time.Time.Format":                     "time.Time",
+		"pkg.(*List[go.shape.int]).Push.func1": "pkg.(*List[go.shape.int])",
+	} {
+		fi := &funcInfo{fn: &gosym.Func{Sym: &gosym.Sym{Name: name}}}
+		if got := funcType(fi); got != want {
+			t.Errorf("funcType(%q) = %v; want %v", name, got, want)
+		}
+	}
+}
+
+func TestFuncRecv(t *testing.T) {
+	for name, want := range map[string]any{
+		"main.main":                            nil,
+		"pkg.Foo.func1":                        nil,
+		"pkg.glob..func1":                      nil,
+		"net/http.(*Server).Serve.func2.1":     "(*Server)",
+		"time.Time.Format":                     "Time",
+		"pkg.(*List[go.shape.int]).Push.func1": "(*List[go.shape.int])",
+	} {
+		fi := &funcInfo{fn: &gosym.Func{Sym: &gosym.Sym{Name: name}}}
+		if got := funcRecv(fi); got != want {
+			t.Errorf("funcRecv(%q) = %v; want %v", name, got, want)
+		}
+	}
+}
+
+func TestDiffModules(t *testing.T) {
+	a := []ModuleRec{
+		{Module: "std", ModuleVersion: "go1.21.0", Size: 1000},
+		{Module: "example.com/a", ModuleVersion: "v1.0.0", Size: 100},
+		{Module: "example.com/b", ModuleVersion: "v1.0.0", Size: 50},
+		{Module: "example.com/c", ModuleVersion: "v1.0.0", Size: 10},
+	}
+	b := []ModuleRec{
+		{Module: "std", ModuleVersion: "go1.21.0", Size: 1000},
+		{Module: "example.com/a", ModuleVersion: "v1.1.0", Size: 120},
+		{Module: "example.com/c", ModuleVersion: "v1.0.0", Size: 15},
+		{Module: "example.com/d", ModuleVersion: "v0.1.0", Size: 30},
+	}
+	got := DiffModules(a, b)
+	want := []ModuleRec{
+		{Module: "example.com/b", BaseVersion: "v1.0.0", Size: -50},
+		{Module: "example.com/c", ModuleVersion: "v1.0.0", Size: 5},
+		{Module: "example.com/a", ModuleVersion: "v1.1.0", BaseVersion: "v1.0.0", Size: 20},
+		{Module: "example.com/d", ModuleVersion: "v0.1.0", Size: 30},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffModules = %+v; want %+v", got, want)
+	}
+}
+
+// TestModulesMode tests that modules mode attributes sizes to the
+// modules in a binary's build info, and that a gzipped modules
+// output works as a --base.
+func TestModulesMode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping binary build in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		"go.mod":         "module example.com/prog\n\ngo 1.21\n\nrequire example.com/dep v1.0.0\n\nreplace example.com/dep v1.0.0 => ./dep\n",
+		"main.go":        "package main\n\nimport \"example.com/dep/sub\"\n\nfunc main() { println(sub.F(len(\"x\"))) }\n",
+		"dep/go.mod":     "module example.com/dep\n\ngo 1.21\n",
+		"dep/sub/sub.go": "package sub\n\n//go:noinline\nfunc F(n int) int {\n\tfor i := 0; i < n; i++ {\n\t\tn += i * n\n\t}\n\treturn n\n}\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(goTool, "build", "-o", "prog", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0", "GOFLAGS=", "GOWORK=off", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building test binary: %v\n%s", err, out)
+	}
+	prog := filepath.Join(dir, "prog")
+	bi, err := buildinfo.ReadFile(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bi.Deps) == 0 {
+		t.Fatal("test binary has no dependencies")
+	}
+
+	out, _ := runShotizam(t, "--mode=modules", prog)
+	var recs []ModuleRec
+	if err := json.Unmarshal(out, &recs); err != nil {
+		t.Fatal(err)
+	}
+	byModule := map[string]ModuleRec{}
+	for _, mr := range recs {
+		byModule[mr.Module] = mr
+	}
+	for _, dep := range bi.Deps {
+		mr := byModule[dep.Path]
+		want := ModuleRec{Module: dep.Path, ModuleVersion: dep.Version, Size: mr.Size}
+		if dep.Replace != nil {
+			want.Replace, want.ModuleVersion = dep.Replace.Path, dep.Replace.Version
+		}
+		if mr.Size <= 0 || mr != want {
+			t.Errorf("module %s = %+v; want %+v with a positive size", dep.Path, mr, want)
+		}
+	}
+	if mr := byModule[bi.Main.Path]; mr.Size <= 0 {
+		t.Errorf("main module %s size = %d; want positive", bi.Main.Path, mr.Size)
+	}
+	if mr := byModule["std"]; mr.Size <= 0 || mr.ModuleVersion != bi.GoVersion {
+		t.Errorf("std = %+v; want positive size and version %s", mr, bi.GoVersion)
+	}
+
+	base := filepath.Join(dir, "base.json.gz")
+	runShotizam(t, "--mode=modules", "--out="+base, prog)
+	if out, _ := runShotizam(t, "--mode=modules", "--base="+base, prog); strings.TrimSpace(string(out)) != "null" {
+		t.Errorf("diff against itself = %s; want null", out)
+	}
+}
+
+func TestPkgDiff(t *testing.T) {
+	rec := func(name, pkg, what string, size int64) Rec {
+		return Rec{RecKey: RecKey{Name: name, Package: pkg, What: what}, Size: size}
+	}
+	a := []Rec{
+		rec("net/http.a", "net/http", "text", 100),
+		rec("net/http.b", "net/http", "text", 50),
+		rec("net/http.a", "net/http", "pcln", 10),
+		rec("net/http.a", "net/http", "pcsp", 5),
+		rec("os.a", "os", "text", 1000),
+	}
+	b := []Rec{
+		rec("net/http.a", "net/http", "text", 120),
+		rec("net/http.a", "net/http", "pcln", 10),
+		rec("net/http.a", "net/http", "funcname", 11),
+		rec("os.a", "os", "text", 2000),
+	}
+	got := PkgDiff(a, b, "net/http")
+	want := []WhatDiff{
+		{What: "text", Base: 150, Size: 120},
+		{What: "pcsp", Base: 5, Size: 0},
+		{What: "pcln", Base: 10, Size: 10},
+		{What: "funcname", Base: 0, Size: 11},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PkgDiff = %+v; want %+v", got, want)
+	}
+}
+
+// TestWindowsCArchive checks that the go.o of a Windows
+// -buildmode=c-archive build is found in the GNU-flavored ar archive
+// that MinGW's ar wraps it in.
+func TestWindowsCArchive(t *testing.T) {
+	dir := t.TempDir()
+	windowsGoObj(t, dir, "amd64")
+	// A member name over 15 bytes makes GNU ar write a "//" long
+	// name table, as MinGW's does for the cgo objects.
+	if err := os.Link(filepath.Join(dir, "go.o"), filepath.Join(dir, "_cgo_export_windows_amd64.o")); err != nil {
+		t.Fatal(err)
+	}
+	runAr(t, dir, "rcs", "prog.lib", "_cgo_export_windows_amd64.o", "go.o")
+
+	lib, err := os.ReadFile(filepath.Join(dir, "prog.lib"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(lib, []byte("//              ")) {
+		t.Fatal("archive has no GNU long name table")
+	}
+	f, err := Open(bytes.NewReader(lib), int64(len(lib)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	tab, err := gosym.NewTable(nil, gosym.NewLineTable(f.Gopclntab, f.TextOffset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tab.LookupFunc("main.main") == nil {
+		t.Error("main.main not found")
+	}
+}
+
+// TestMultiArchArchive checks that --arch picks among the go.o
+// members of an archive with one per architecture.
+func TestMultiArchArchive(t *testing.T) {
+	dir := t.TempDir()
+	for _, goarch := range []string{"amd64", "386"} {
+		os.Mkdir(filepath.Join(dir, goarch), 0755)
+		windowsGoObj(t, filepath.Join(dir, goarch), goarch)
+	}
+	runAr(t, dir, "q", "prog.a", "amd64/go.o", "386/go.o")
+	a, err := os.ReadFile(filepath.Join(dir, "prog.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { *arch = "" }()
+
+	*arch = ""
+	_, err = Open(bytes.NewReader(a), int64(len(a)))
+	if err == nil || !strings.Contains(err.Error(), "amd64, 386") {
+		t.Errorf("Open without --arch = %v; want error listing arches", err)
+	}
+	for _, goarch := range []string{"amd64", "386"} {
+		*arch = goarch
+		f, err := Open(bytes.NewReader(a), int64(len(a)))
+		if err != nil {
+			t.Fatalf("Open with --arch=%s: %v", goarch, err)
+		}
+		if f.Arch != goarch {
+			t.Errorf("--arch=%s opened %s go.o", goarch, f.Arch)
+		}
+	}
+
+	*arch = "all"
+	f, err := Open(bytes.NewReader(a), int64(len(a)))
+	if err != nil {
+		t.Fatalf("Open with --arch=all: %v", err)
+	}
+	if len(f.Others) != 1 || f.Arch != "amd64" || f.Others[0].Arch != "386" {
+		t.Errorf("--arch=all opened %s go.o with %d others; want amd64 then 386", f.Arch, len(f.Others))
+	}
+}
+
+// windowsGoObj writes to dir the go.o of a Windows
+// -buildmode=c-archive build of an empty program. Building the
+// archive itself needs a MinGW toolchain, so this relies on the
+// linker writing go.o before it fails to run the external archiver.
+func windowsGoObj(t *testing.T, dir, goarch string) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping c-archive build in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goTool, "build", "-buildmode=c-archive", "-ldflags=-tmpdir="+dir, "-o", "prog.a", "main.go")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=windows", "GOARCH="+goarch, "CGO_ENABLED=0", "GO111MODULE=off")
+	out, _ := cmd.CombinedOutput()
+	if _, err := os.Stat(filepath.Join(dir, "go.o")); err != nil {
+		t.Skipf("linker didn't write go.o: %s", out)
+	}
+}
+
+// runAr runs the host's (GNU) ar in dir.
+func runAr(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	arTool, err := exec.LookPath("ar")
+	if err != nil {
+		t.Skip("ar not found")
+	}
+	cmd := exec.Command(arTool, args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("ar: %v\n%s", err, out)
+	}
+}
+
+const testProg = "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"hi\") }\n"
+
+// buildTestProg builds testProg for goos/goarch with the given extra
+// build flags and returns the binary's contents.
+func buildTestProg(t *testing.T, goos, goarch string, flags ...string) []byte {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping binary build in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(testProg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := append(append([]string{"build", "-o", "prog"}, flags...), "main.go")
+	cmd := exec.Command(goTool, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH="+goarch, "CGO_ENABLED=0", "GO111MODULE=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building test binary: %v\n%s", err, out)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "prog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestWasm(t *testing.T) {
+	b := buildTestProg(t, "js", "wasm")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	tab, err := gosym.NewTable(nil, gosym.NewLineTable(f.Gopclntab, f.TextOffset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tab.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	var text int64
+	for i := range tab.Funcs {
+		text += f.funcTextSize(&tab.Funcs[i])
+	}
+	var code int64
+	for _, size := range f.wasmFuncSizes {
+		code += size
+	}
+	if text != code {
+		t.Errorf("functions' text totals %d bytes; want code section's %d", text, code)
+	}
+	fn := tab.LookupFunc("main.main")
+	if fn == nil {
+		t.Fatal("main.main not found")
+	}
+	if size := f.funcTextSize(fn); size == 0 {
+		t.Error("main.main has no text")
+	}
+}
+
+func TestMachOUniversal(t *testing.T) {
+	amd64 := buildTestProg(t, "darwin", "amd64")
+	arm64 := buildTestProg(t, "darwin", "arm64")
+
+	slices := []fatSlice{{macho.CpuAmd64, amd64}, {macho.CpuArm64, arm64}}
+	fat := fatMachO(slices)
+
+	for _, tt := range []struct {
+		arch string
+		want int // index of wanted slice
+	}{{"amd64", 0}, {"arm64", 1}} {
+		*arch = tt.arch
+		f, err := Open(bytes.NewReader(fat), int64(len(fat)))
+		if err != nil {
+			t.Fatalf("Open with --arch=%q: %v", tt.arch, err)
+		}
+		if got, want := f.Size, int64(len(slices[tt.want].b)); got != want {
+			t.Errorf("--arch=%q: Size = %d; want %d", tt.arch, got, want)
+		}
+		tab, err := gosym.NewTable(nil, gosym.NewLineTable(f.Gopclntab, f.TextOffset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tab.Validate(); err != nil {
+			t.Errorf("--arch=%q: %v", tt.arch, err)
+		}
+	}
+
+	defer func() { *arch = "" }()
+	for _, a := range []string{"", "ppc64"} {
+		*arch = a
+		_, err := Open(bytes.NewReader(fat), int64(len(fat)))
+		if err == nil || !strings.Contains(err.Error(), "amd64, arm64") {
+			t.Errorf("Open with --arch=%q = %v; want error listing arches", a, err)
+		}
+	}
+}
+
+// TestMachOUniversalBadSlice tests that a slice of a universal binary
+// that can't be parsed doesn't stop the others from being analyzed.
+func TestMachOUniversalBadSlice(t *testing.T) {
+	amd64 := buildTestProg(t, "darwin", "amd64")
+
+	// A Mach-O header with no load commands parses, but has no
+	// text or pclntab for shotizam.
+	var bad bytes.Buffer
+	binary.Write(&bad, binary.LittleEndian, []uint32{macho.Magic64, uint32(macho.CpuArm64), 0, uint32(macho.TypeExec), 0, 0, 0, 0})
+	fat := fatMachO([]fatSlice{{macho.CpuAmd64, amd64}, {macho.CpuArm64, bad.Bytes()}})
+
+	defer func() { *arch = "" }()
+	*arch = "amd64"
+	f, err := Open(bytes.NewReader(fat), int64(len(fat)))
+	if err != nil {
+		t.Fatalf("Open with --arch=amd64: %v", err)
+	}
+	if f.Arch != "amd64" {
+		t.Errorf("Arch = %q; want amd64", f.Arch)
+	}
+
+	*arch = "arm64"
+	if _, err := Open(bytes.NewReader(fat), int64(len(fat))); err == nil || !strings.Contains(err.Error(), "arm64 slice") {
+		t.Errorf("Open with --arch=arm64 = %v; want arm64 slice error", err)
+	}
+
+	*arch = allArches
+	f, err = Open(bytes.NewReader(fat), int64(len(fat)))
+	if err != nil {
+		t.Fatalf("Open with --arch=all: %v", err)
+	}
+	if f.Arch != "amd64" || len(f.Others) != 0 {
+		t.Errorf("--arch=all opened %s and %d others; want only amd64", f.Arch, len(f.Others))
+	}
+}
+
+// fatSlice is a slice of a universal binary for fatMachO.
+type fatSlice struct {
+	cpu macho.Cpu
+	b   []byte
+}
+
+// fatMachO lays out a universal binary of slices as lipo does: a
+// big-endian header and table of slices, with slices aligned to 2^14
+// bytes.
+func fatMachO(slices []fatSlice) []byte {
+	const align = 14
+	var fat bytes.Buffer
+	binary.Write(&fat, binary.BigEndian, []uint32{macho.MagicFat, uint32(len(slices))})
+	off := uint32(1 << align)
+	for _, s := range slices {
+		binary.Write(&fat, binary.BigEndian, []uint32{uint32(s.cpu), 0, off, uint32(len(s.b)), align})
+		off += (uint32(len(s.b)) + 1<<align - 1) &^ (1<<align - 1)
+	}
+	for _, s := range slices {
+		fat.Write(make([]byte, (fat.Len()+1<<align-1)&^(1<<align-1)-fat.Len()))
+		fat.Write(s.b)
+	}
+	return fat.Bytes()
+}
+
+func TestPEDataSections(t *testing.T) {
+	b := buildTestProg(t, "windows", "amd64")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	pf, err := pe.NewFile(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]SectionSize{}
+	for _, s := range f.DataSections {
+		got[s.Name] = s
+		if s.FileSize > s.Size || s.Size < 0 {
+			t.Errorf("section %s has bogus sizes %+v", s.Name, s)
+		}
+	}
+	rdata, ok := got[".rdata"]
+	if !ok {
+		t.Fatalf("no .rdata in %+v", f.DataSections)
+	}
+	// The pclntab is in .rdata, but accounted for separately.
+	if want := int64(pf.Section(".rdata").VirtualSize) - int64(len(f.Gopclntab)); rdata.Size != want {
+		t.Errorf(".rdata size = %d; want %d", rdata.Size, want)
+	}
+	if _, ok := got[".data"]; !ok {
+		t.Errorf("no .data in %+v", f.DataSections)
+	}
+}
+
+func TestELFDataSections(t *testing.T) {
+	b := buildTestProg(t, "linux", "amd64")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got := map[string]SectionSize{}
+	for _, s := range f.DataSections {
+		got[s.Name] = s
+	}
+	if s := got[".rodata"]; s.Size == 0 || s.FileSize != s.Size {
+		t.Errorf(".rodata = %+v; want non-empty and all in the file", s)
+	}
+	if s := got[".bss"]; s.Size == 0 || s.FileSize != 0 {
+		t.Errorf(".bss = %+v; want non-empty and not in the file", s)
+	}
+}
+
+func TestMachODataSections(t *testing.T) {
+	b := buildTestProg(t, "darwin", "arm64")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got := map[string]SectionSize{}
+	for _, s := range f.DataSections {
+		got[s.Name] = s
+	}
+	if s := got["__TEXT,__rodata"]; s.Size == 0 || s.FileSize != s.Size {
+		t.Errorf("__TEXT,__rodata = %+v; want non-empty and all in the file", s)
+	}
+	if s := got["__DATA,__bss"]; s.Size == 0 || s.FileSize != 0 {
+		t.Errorf("__DATA,__bss = %+v; want non-empty and not in the file", s)
+	}
+	for _, name := range []string{"__DATA_CONST,__go_type", "__DATA_CONST,__got"} {
+		if s := got[name]; s.Size == 0 || s.FileSize != s.Size {
+			t.Errorf("%s = %+v; want non-empty and all in the file", name, s)
+		}
+	}
+	gaps := map[string]int64{}
+	for _, g := range f.Gaps {
+		gaps[g.Name] = g.FileSize
+	}
+	for _, name := range []string{"header", "segment:__LINKEDIT"} {
+		if gaps[name] <= 0 {
+			t.Errorf("no %s in gaps %v", name, gaps)
+		}
+	}
+}
+
+func TestCompressed(t *testing.T) {
+	b := buildTestProg(t, "linux", "amd64")
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(b)
+	zw.Close()
+	compressed := map[string][]byte{"gzip": gz.Bytes()}
+	if xzBin, err := exec.LookPath("xz"); err == nil {
+		cmd := exec.Command(xzBin, "--stdout")
+		cmd.Stdin = bytes.NewReader(b)
+		xz, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		compressed["xz"] = xz
+	}
+	for name, c := range compressed {
+		f, err := Open(bytes.NewReader(c), int64(len(c)))
+		if err != nil {
+			t.Errorf("%s: Open: %v", name, err)
+			continue
+		}
+		if f.Size != int64(len(b)) {
+			t.Errorf("%s: Size = %d; want decompressed size %d", name, f.Size, len(b))
+		}
+	}
+}
+
+func TestStripped(t *testing.T) {
+	b := buildTestProg(t, "aix", "ppc64", "-ldflags=-s -w")
+	_, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != errStripped {
+		t.Errorf("Open = %v; want %v", err, errStripped)
+	}
+}
+
+func TestPEStripped(t *testing.T) {
+	b := buildTestProg(t, "windows", "amd64", "-ldflags=-s -w")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	tab, err := gosym.NewTable(nil, gosym.NewLineTable(f.Gopclntab, f.TextOffset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tab.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if tab.LookupFunc("main.main") == nil {
+		t.Error("main.main not found")
+	}
+
+	// The pclntab found by its header is the same as by its
+	// symbols, less the funcdata and findfunctab the symbols'
+	// range also covers.
+	unstripped := buildTestProg(t, "windows", "amd64")
+	uf, err := Open(bytes.NewReader(unstripped), int64(len(unstripped)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Gopclntab) > len(uf.Gopclntab) || !bytes.Equal(f.Gopclntab[:100], uf.Gopclntab[:100]) {
+		t.Errorf("pclntab by header is %d bytes; by symbols, %d", len(f.Gopclntab), len(uf.Gopclntab))
+	}
+}
+
+// buildDebugLinked builds a linux/amd64 binary, unstripped, and
+// splits a copy of it with objcopy, as distributions do, into a
+// stripped binary, stripped, whose .gnu_debuglink names its debug
+// file, debug, in the same directory.
+func buildDebugLinked(t *testing.T) (unstripped, stripped, debug string) {
+	t.Helper()
+	objcopy, err := exec.LookPath("objcopy")
+	if err != nil {
+		t.Skip("objcopy not found")
+	}
+	b := buildTestProg(t, "linux", "amd64")
+	dir := t.TempDir()
+	unstripped = filepath.Join(dir, "prog.unstripped")
+	if err := os.WriteFile(unstripped, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	stripped, debug = filepath.Join(dir, "prog"), filepath.Join(dir, "prog.debug")
+	for _, args := range [][]string{
+		{"--only-keep-debug", unstripped, debug},
+		{"--strip-all", "--add-gnu-debuglink=" + debug, unstripped, stripped},
+	} {
+		if out, err := exec.Command(objcopy, args...).CombinedOutput(); err != nil {
+			t.Fatalf("objcopy %q: %v\n%s", args, err, out)
+		}
+	}
+	return unstripped, stripped, debug
+}
+
+// writeMismatchedDebugFile writes a copy of the debug file debug to
+// path with a byte appended, so it's still a valid debug file but
+// doesn't match the CRC in its binary's .gnu_debuglink.
+func writeMismatchedDebugFile(t *testing.T, debug, path string) {
+	t.Helper()
+	b, err := os.ReadFile(debug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, append(b, 0), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseDebugLink(t *testing.T) {
+	unstripped, stripped, debug := buildDebugLinked(t)
+	ef, err := elf.Open(stripped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ef.Close()
+	name, crc, ok := parseDebugLink(ef)
+	if want := fileCRC(debug); !ok || name != "prog.debug" || crc != want {
+		t.Errorf("parseDebugLink = %q, %#x, %v; want %q, %#x, true", name, crc, ok, "prog.debug", want)
+	}
+
+	uf, err := elf.Open(unstripped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uf.Close()
+	if _, _, ok := parseDebugLink(uf); ok {
+		t.Error("parseDebugLink of binary without a .gnu_debuglink section reports ok")
+	}
+}
+
+func TestDebugFilePath(t *testing.T) {
+	_, stripped, debug := buildDebugLinked(t)
+	dotDebug := filepath.Join(filepath.Dir(stripped), ".debug", "prog.debug")
+	if err := os.Mkdir(filepath.Dir(dotDebug), 0755); err != nil {
+		t.Fatal(err)
+	}
+	lookup := func() string {
+		t.Helper()
+		f, err := os.Open(stripped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		return debugFilePath(stripped, f)
+	}
+
+	good, err := os.ReadFile(debug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dotDebug, good, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := lookup(); got != debug {
+		t.Errorf("with both debug files, debugFilePath = %q; want %q", got, debug)
+	}
+	writeMismatchedDebugFile(t, dotDebug, debug)
+	if got := lookup(); got != dotDebug {
+		t.Errorf("with a mismatched CRC next to the binary, debugFilePath = %q; want %q", got, dotDebug)
+	}
+	writeMismatchedDebugFile(t, debug, dotDebug)
+	if got := lookup(); got != "" {
+		t.Errorf("with only mismatched CRCs, debugFilePath = %q; want none", got)
+	}
+
+	if err := os.WriteFile(dotDebug, good, 0644); err != nil {
+		t.Fatal(err)
+	}
+	*debugFile = dotDebug
+	defer func() { *debugFile = "" }()
+	if got := lookup(); got != dotDebug {
+		t.Errorf("with --debug-file, debugFilePath = %q; want %q", got, dotDebug)
+	}
+}
+
+// TestDebugFile tests that a stripped binary with its debug file has
+// the same text, variables, strings, and types as before stripping.
+func TestDebugFile(t *testing.T) {
+	unstripped, stripped, _ := buildDebugLinked(t)
+	whatSizes := func(bin string) map[string]int64 {
+		t.Helper()
+		out, _ := runShotizam(t, "--mode=json", bin)
+		var recs []Rec
+		if err := json.Unmarshal(out, &recs); err != nil {
+			t.Fatal(err)
+		}
+		m := make(map[string]int64)
+		for _, r := range recs {
+			switch r.What {
+			case "text", "var", "strings", "rtti":
+				m[r.What] += r.Size
+			}
+		}
+		return m
+	}
+	want := whatSizes(unstripped)
+	if len(want) != 4 {
+		t.Fatalf("unstripped binary has sizes %v; want text, var, strings, and rtti", want)
+	}
+	if got := whatSizes(stripped); !reflect.DeepEqual(got, want) {
+		t.Errorf("stripped binary with debug file has sizes %v; want %v", got, want)
+	}
+}
+
+func TestDebugFileMismatch(t *testing.T) {
+	unstripped, stripped, debug := buildDebugLinked(t)
+	dir := t.TempDir()
+	notELF := filepath.Join(dir, "not-elf.debug")
+	if err := os.WriteFile(notELF, []byte("not an ELF file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mismatched := filepath.Join(dir, "mismatched.debug")
+	writeMismatchedDebugFile(t, debug, mismatched)
+	for _, tt := range []struct {
+		bin, debugFile, warning string
+	}{
+		{unstripped, notELF, "ignoring debug file"},
+		{stripped, mismatched, "doesn't match"},
+	} {
+		if _, stderr := runShotizam(t, "--mode=tsv", "--debug-file="+tt.debugFile, tt.bin); !bytes.Contains(stderr, []byte(tt.warning)) {
+			t.Errorf("--debug-file=%s: no %q warning; stderr:\n%s", tt.debugFile, tt.warning, stderr)
+		}
+		cmd := exec.Command(os.Args[0], "--mode=tsv", "--strict", "--debug-file="+tt.debugFile, tt.bin)
+		cmd.Env = append(os.Environ(), runShotizamEnv+"=1")
+		out, err := cmd.CombinedOutput()
+		if ee, ok := err.(*exec.ExitError); !ok || ee.ExitCode() != 1 || !bytes.Contains(out, []byte(tt.warning)) {
+			t.Errorf("--debug-file=%s --strict = %v; want exit status 1 with %q\n%.500s", tt.debugFile, err, tt.warning, out)
+		}
+	}
+}
+
+// buildCShared builds a linux/amd64 -buildmode=c-shared library
+// and returns its path.
+func buildCShared(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping c-shared build in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	dir := t.TempDir()
+	const src = "package main\n\nimport \"C\"\n\n//export Hello\n//go:noinline\nfunc Hello() int { return 42 }\n\nfunc main() {}\n"
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goTool, "build", "-buildmode=c-shared", "-o", "libprog.so", "main.go")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=1", "GO111MODULE=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("can't build c-shared library: %v\n%s", err, out)
+	}
+	return filepath.Join(dir, "libprog.so")
+}
+
+func TestCShared(t *testing.T) {
+	so := buildCShared(t)
+	b, err := os.ReadFile(so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	tab, err := gosym.NewTable(nil, gosym.NewLineTable(f.Gopclntab, f.TextOffset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tab.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if tab.LookupFunc("main.Hello") == nil {
+		t.Error("main.Hello not found")
+	}
+
+	// Finding the pclntab by its symbols gets the same bytes as
+	// by its section.
+	ef, err := elf.Open(so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ef.Close()
+	syms, err := ef.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := elfSymData(ef, syms, "runtime.pclntab", "runtime.epclntab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, f.Gopclntab) {
+		t.Errorf("pclntab by symbol is %d bytes; by section, %d", len(got), len(f.Gopclntab))
+	}
+}
+
+func TestAPK(t *testing.T) {
+	so, err := os.ReadFile(buildCShared(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var apk bytes.Buffer
+	zw := zip.NewWriter(&apk)
+	for _, name := range []string{"lib/x86_64/libother.so", "lib/x86_64/libgojni.so", "classes.dex"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == "lib/x86_64/libgojni.so" {
+			w.Write(so)
+		} else {
+			w.Write([]byte("not Go"))
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { *apkLib = "" }()
+	for _, lib := range []string{"", "lib/x86_64/libgojni.so"} {
+		*apkLib = lib
+		f, err := Open(bytes.NewReader(apk.Bytes()), int64(apk.Len()))
+		if err != nil {
+			t.Fatalf("Open with --apk-lib=%q: %v", lib, err)
+		}
+		if f.Size != int64(len(so)) {
+			t.Errorf("--apk-lib=%q: Size = %d; want the library's %d", lib, f.Size, len(so))
+		}
+	}
+	*apkLib = "lib/x86_64/libother.so"
+	if _, err := Open(bytes.NewReader(apk.Bytes()), int64(apk.Len())); err == nil {
+		t.Error("Open of non-Go library succeeded")
+	}
+}
+
+func TestXCOFF(t *testing.T) {
+	b := buildTestProg(t, "aix", "ppc64")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	tab, err := gosym.NewTable(nil, gosym.NewLineTable(f.Gopclntab, f.TextOffset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tab.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if tab.LookupFunc("main.main") == nil {
+		t.Error("main.main not found")
+	}
+	if err := checkTextOffset(tab, f); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestCheckTextOffsetLazy tests that checkTextOffset checks the
+// lazy tables of --stream, whose Funcs are empty, too.
+func TestCheckTextOffsetLazy(t *testing.T) {
+	b := buildTestProg(t, "linux", "amd64")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	tab, err := gosym.NewLazyTable(gosym.NewLineTable(f.Gopclntab, f.TextOffset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkTextOffset(tab, f); err != nil {
+		t.Error(err)
+	}
+	bad := *f
+	bad.TextOffset += 1 << 40
+	tab, err = gosym.NewLazyTable(gosym.NewLineTable(bad.Gopclntab, bad.TextOffset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkTextOffset(tab, &bad); err == nil {
+		t.Error("no error for a wrong text offset")
+	}
+}
+
+// TestWrongTextOffset tests that a text offset that disagrees with
+// the text section, here from a runtime.text symbol set to the text's
+// file offset rather than its address, is warned about, and fatal
+// with --strict.
+func TestWrongTextOffset(t *testing.T) {
+	b := buildTestProg(t, "linux", "amd64")
+	ef, err := elf.NewFile(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	symtab, text := ef.Section(".symtab"), ef.Section(".text")
+	strtab := ef.Sections[symtab.Link]
+	patched := false
+	for off := symtab.Offset; off+24 <= symtab.Offset+symtab.Size; off += 24 {
+		name := strtab.Offset + uint64(binary.LittleEndian.Uint32(b[off:]))
+		if bytes.HasPrefix(b[name:], []byte("runtime.text\x00")) {
+			binary.LittleEndian.PutUint64(b[off+8:], text.Offset)
+			patched = true
+			break
+		}
+	}
+	if !patched {
+		t.Fatal("no runtime.text symbol")
+	}
+	path := filepath.Join(t.TempDir(), "prog")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr := runShotizam(t, "--mode=tsv", path); !bytes.Contains(stderr, []byte("text offset")) {
+		t.Errorf("no text offset warning; stderr:\n%s", stderr)
+	}
+	cmd := exec.Command(os.Args[0], "--mode=tsv", "--strict", path)
+	cmd.Env = append(os.Environ(), runShotizamEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if ee, ok := err.(*exec.ExitError); !ok || ee.ExitCode() != 1 || !bytes.Contains(out, []byte("text offset")) {
+		t.Errorf("shotizam --strict = %v; want exit status 1 with text offset error\n%.500s", err, out)
+	}
+}
+
+func TestPlan9(t *testing.T) {
+	b := buildTestProg(t, "plan9", "amd64")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	tab, err := gosym.NewTable(nil, gosym.NewLineTable(f.Gopclntab, f.TextOffset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tab.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if tab.LookupFunc("main.main") == nil {
+		t.Error("main.main not found")
+	}
+}
+
+func TestPIE(t *testing.T) {
+	for _, flags := range [][]string{
+		{"-buildmode=pie"},
+		{"-buildmode=pie", "-ldflags=-s"},
+	} {
+		b := buildTestProg(t, "linux", "amd64", flags...)
+		f, err := Open(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%v: Open: %v", flags, err)
+		}
+		tab, err := gosym.NewTable(nil, gosym.NewLineTable(f.Gopclntab, f.TextOffset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkTextOffset(tab, f); err != nil {
+			t.Errorf("%v: %v", flags, err)
+		}
+		ef, err := elf.NewFile(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := ef.Section(".text")
+		var sum uint64
+		for i := range tab.Funcs {
+			fn := &tab.Funcs[i]
+			if fn.Entry < text.Addr || fn.End > text.Addr+text.Size {
+				t.Fatalf("%v: %s at [%#x,%#x) is outside .text [%#x,%#x)", flags, fn.Name, fn.Entry, fn.End, text.Addr, text.Addr+text.Size)
+			}
+			sum += fn.End - fn.Entry
+		}
+		if sum > text.Size {
+			t.Errorf("%v: function sizes sum to %d; .text is only %d bytes", flags, sum, text.Size)
+		}
+	}
+}
+
+func TestDWARFVars(t *testing.T) {
+	*dwarfVars = true
+	defer func() { *dwarfVars = false }()
+	for _, goos := range []string{"linux", "darwin", "windows"} {
+		b := buildTestProg(t, goos, "amd64")
+		f, err := Open(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%s: Open: %v", goos, err)
+		}
+		byPkg, bySection := f.varSizes()
+		if byPkg["runtime"] == 0 {
+			t.Errorf("%s: no runtime variables found; got %v", goos, byPkg)
+		}
+		for _, s := range f.DataSections {
+			if bySection[s.Name] > s.Size {
+				t.Errorf("%s: %s has %d bytes of variables; more than its size of %d", goos, s.Name, bySection[s.Name], s.Size)
+			}
+		}
+	}
+}
+
+func TestELFDataSyms(t *testing.T) {
+	b := buildTestProg(t, "linux", "amd64")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	byPkg, bySection := f.varSizes()
+	if byPkg["runtime"] == 0 {
+		t.Errorf("no runtime data symbols found; got %v", byPkg)
+	}
+	for _, s := range f.DataSections {
+		if bySection[s.Name] > s.Size {
+			t.Errorf("%s has %d bytes of symbols; more than its size of %d", s.Name, bySection[s.Name], s.Size)
+		}
+	}
+}
+
+func TestMachOSymData(t *testing.T) {
+	b := buildTestProg(t, "darwin", "arm64")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	mo, err := macho.NewFile(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Finding the pclntab by its symbols gets the same bytes as
+	// by its section.
+	got, err := machoSymData(mo, "runtime.pclntab", "runtime.epclntab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, f.Gopclntab) {
+		t.Errorf("pclntab by symbol is %d bytes; by section, %d", len(got), len(f.Gopclntab))
+	}
+}
+
+func TestTextOffsetIsVirtual(t *testing.T) {
+	for _, goos := range []string{"darwin", "windows"} {
+		b := buildTestProg(t, goos, "amd64")
+		f, err := Open(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%s: Open: %v", goos, err)
+		}
+		var textAddr, textSize uint64
+		switch goos {
+		case "darwin":
+			mo, err := macho.NewFile(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := mo.Section("__text")
+			textAddr, textSize = text.Addr, text.Size
+		case "windows":
+			pf, err := pe.NewFile(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := pf.Section(".text")
+			textAddr = pf.OptionalHeader.(*pe.OptionalHeader64).ImageBase + uint64(text.VirtualAddress)
+			textSize = uint64(text.VirtualSize)
+		}
+		if f.TextOffset != textAddr {
+			t.Errorf("%s: TextOffset = %#x; want .text address %#x", goos, f.TextOffset, textAddr)
+		}
+		tab, err := gosym.NewTable(nil, gosym.NewLineTable(f.Gopclntab, f.TextOffset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sum uint64
+		for _, fn := range tab.Funcs {
+			sum += fn.End - fn.Entry
+		}
+		if last := tab.Funcs[len(tab.Funcs)-1]; last.End > textAddr+textSize || sum > textSize {
+			t.Errorf("%s: functions end at %#x and total %d bytes; want within .text [%#x,%#x)", goos, last.End, sum, textAddr, textAddr+textSize)
+		}
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	b := buildTestProg(t, "linux", "amd64")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if f.BuildInfo == nil {
+		t.Fatal("no BuildInfo")
+	}
+	if !strings.HasPrefix(f.BuildInfo.GoVersion, "go") {
+		t.Errorf("GoVersion = %q; want a Go version", f.BuildInfo.GoVersion)
+	}
+	if !strings.Contains(f.BuildInfo.String(), "GOOS=linux") {
+		t.Errorf("build info lacks the GOOS setting:\n%s", f.BuildInfo)
+	}
+}
+
+func TestSections(t *testing.T) {
+	for _, goos := range []string{"linux", "darwin", "windows"} {
+		b := buildTestProg(t, goos, "amd64")
+		f, err := Open(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%s: Open: %v", goos, err)
+		}
+		var buf bytes.Buffer
+		writeSections(&buf, f)
+		var total int64
+		var sawText bool
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			fields := strings.Split(line, "\t")
+			if len(fields) != 3 {
+				t.Fatalf("%s: bad line %q", goos, line)
+			}
+			size, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				t.Fatalf("%s: bad line %q", goos, line)
+			}
+			total += size
+			sawText = sawText || strings.Contains(fields[0], "text") && size > 0
+		}
+		if total != f.Size {
+			t.Errorf("%s: sections total %d bytes; want the file size, %d", goos, total, f.Size)
+		}
+		if !sawText {
+			t.Errorf("%s: no text section in:\n%s", goos, buf.Bytes())
+		}
+	}
+}
+
+func TestOtherSections(t *testing.T) {
+	b := buildTestProg(t, "linux", "amd64")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	other := map[string]bool{}
+	for _, s := range f.otherSections() {
+		other[s.Name] = true
+	}
+	for _, name := range []string{".symtab", ".go.buildinfo"} {
+		if !other[name] {
+			t.Errorf("%s not in other sections %v", name, other)
+		}
+	}
+	for _, name := range []string{".text", ".gopclntab", ".rodata", ".bss"} {
+		if other[name] {
+			t.Errorf("%s in other sections, but is accounted for elsewhere", name)
+		}
+	}
+}
+
+func TestRowsSumToFileSize(t *testing.T) {
+	for _, tt := range []struct{ goos, goarch string }{
+		{"linux", "amd64"},
+		{"windows", "amd64"},
+		{"darwin", "arm64"},
+		{"darwin", "amd64"},
+	} {
+		t.Run(tt.goos+"-"+tt.goarch, func(t *testing.T) {
+			b := buildTestProg(t, tt.goos, tt.goarch)
+			path := filepath.Join(t.TempDir(), "prog")
+			if err := os.WriteFile(path, b, 0644); err != nil {
+				t.Fatal(err)
+			}
+			out, _ := runShotizam(t, "--mode=bin-json", path)
+			var rows []struct {
+				What string
+				Size int64
+			}
+			if err := json.Unmarshal(out, &rows); err != nil {
+				t.Fatal(err)
+			}
+			var sum, todo int64
+			for _, r := range rows {
+				sum += r.Size
+				if r.What == "TODO" {
+					todo = r.Size
+				}
+			}
+			if sum != int64(len(b)) {
+				t.Errorf("sum of sizes = %d; want file size %d", sum, len(b))
+			}
+			if pct := float64(todo) * 100 / float64(len(b)); pct < -1 || pct > 1 {
+				t.Errorf("TODO row has %d bytes (%.1f%%) unaccounted for; want under 1%%", todo, pct)
+			}
+		})
+	}
+}
+
+func TestUnaccountedWarning(t *testing.T) {
+	for _, tt := range []struct{ goos, goarch string }{
+		{"linux", "amd64"},
+		{"darwin", "arm64"},
+	} {
+		t.Run(tt.goos+"-"+tt.goarch, func(t *testing.T) {
+			b := buildTestProg(t, tt.goos, tt.goarch)
+			path := filepath.Join(t.TempDir(), "prog")
+			if err := os.WriteFile(path, b, 0644); err != nil {
+				t.Fatal(err)
+			}
+			// The default threshold is quiet on a normal binary.
+			if _, stderr := runShotizam(t, "--mode=tsv", path); bytes.Contains(stderr, []byte("unaccounted")) {
+				t.Errorf("unexpected warning with the default --max-unaccounted:\n%s", stderr)
+			}
+			// Some bytes, like ELF headers, are always left over.
+			if _, stderr := runShotizam(t, "--mode=tsv", "--max-unaccounted=0.0001", path); !bytes.Contains(stderr, []byte("unaccounted")) {
+				t.Errorf("no warning with --max-unaccounted=0.0001; stderr:\n%s", stderr)
+			}
+		})
+	}
+}
+
+// TestFatalRemovesOut tests that a fatal error after the --out file is
+// opened removes its temporary file.
+func TestFatalRemovesOut(t *testing.T) {
+	b := buildTestProg(t, "linux", "amd64")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prog")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// With --strict, the unaccounted warning is fatal, and comes
+	// after the output is opened.
+	cmd := exec.Command(os.Args[0], "--mode=tsv", "--strict", "--max-unaccounted=0.0001", "--out="+filepath.Join(dir, "out.tsv"), path)
+	cmd.Env = append(os.Environ(), runShotizamEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil || !bytes.Contains(out, []byte("unaccounted")) {
+		t.Fatalf("shotizam = %v; want unaccounted error\n%s", err, out)
+	}
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range ents {
+		if e.Name() != "prog" {
+			t.Errorf("left behind %s", e.Name())
+		}
+	}
+}
+
+func TestFilesModeStream(t *testing.T) {
+	b := buildTestProg(t, "linux", "amd64")
+	path := filepath.Join(t.TempDir(), "prog")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := runShotizam(t, "--mode=files", path)
+	if !bytes.Contains(want, []byte("main.go\t")) {
+		t.Fatalf("files mode output lacks main.go:\n%s", want)
+	}
+	if got, _ := runShotizam(t, "--mode=files", "--stream", path); !bytes.Equal(got, want) {
+		t.Errorf("files mode output with --stream differs:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDWARFSectionName(t *testing.T) {
+	for name, want := range map[string]string{
+		".debug_info":              ".debug_info",
+		".zdebug_line":             ".debug_line",
+		"__DWARF,__debug_info":     ".debug_info",
+		"__DWARF,__zdebug_ranges":  ".debug_ranges",
+		"__DWARF,__debug_gdb_scri": ".debug_gdb_scri",
+		".rodata":                  "",
+		"__TEXT,__text":            "",
+	} {
+		got, ok := dwarfSectionName(name)
+		if got != want || ok != (want != "") {
+			t.Errorf("dwarfSectionName(%q) = %q, %v; want %q", name, got, ok, want)
+		}
+	}
+}
+
+func TestBigEndian(t *testing.T) {
+	for _, goarch := range []string{"s390x", "mips"} {
+		b := buildTestProg(t, "linux", goarch)
+		f, err := Open(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%s: Open: %v", goarch, err)
+		}
+		if f.Arch != goarch {
+			t.Errorf("%s: Arch = %q", goarch, f.Arch)
+		}
+		tab, err := gosym.NewTable(nil, gosym.NewLineTable(f.Gopclntab, f.TextOffset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tab.Validate(); err != nil {
+			t.Fatalf("%s: %v", goarch, err)
+		}
+		if err := checkTextOffset(tab, f); err != nil {
+			t.Errorf("%s: %v", goarch, err)
+		}
+		var text int64
+		for i := range tab.Funcs {
+			text += f.funcTextSize(&tab.Funcs[i])
+		}
+		if text <= 0 || text > f.Size {
+			t.Errorf("%s: function text totals %d bytes, in a %d byte file", goarch, text, f.Size)
+		}
+		if byPkg, _ := f.varSizes(); byPkg["runtime"] == 0 {
+			t.Errorf("%s: no runtime data symbols found", goarch)
+		}
+	}
+}
+
+func TestInlinedCalls(t *testing.T) {
+	b := buildTestProg(t, "linux", "amd64")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	tab, err := gosym.NewTable(nil, gosym.NewLineTable(f.Gopclntab, f.TextOffset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := tab.LookupFunc("main.main")
+	if fn == nil {
+		t.Fatal("main.main not found")
+	}
+	text := f.funcTextSize(fn)
+	if sizes, total := inlinedCalls(fn, text); sizes != nil || total != 0 {
+		t.Errorf("without --inline, inlinedCalls = %v, %d; want nothing", sizes, total)
+	}
+
+	*inline = true
+	defer func() { *inline = false }()
+	sizes, total := inlinedCalls(fn, text)
+	if sizes["fmt.Println"] == 0 || total <= 0 || total > text {
+		t.Errorf("inlinedCalls = %v, %d; want some fmt.Println, within main.main's %d bytes", sizes, total, text)
+	}
+}
+
+// TestInlineBase tests that --inline moves text between functions
+// without changing the total, and emits one row per inlined function.
+func TestInlineBase(t *testing.T) {
+	b := buildTestProg(t, "linux", "amd64")
+	dir := t.TempDir()
+	path, base := filepath.Join(dir, "prog"), filepath.Join(dir, "base.json")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	runShotizam(t, "--mode=json", "--out="+base, path)
+	out, _ := runShotizam(t, "--mode=json", "--inline", "--base="+base, path)
+	var diff []Rec
+	if err := json.Unmarshal(out, &diff); err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	moved := map[RecKey]int64{}
+	for _, r := range diff {
+		if r.What != "text" {
+			t.Errorf("--inline changed %+v", r)
+		}
+		total += r.Size
+		moved[r.RecKey] += r.Size
+	}
+	if total != 0 {
+		t.Errorf("--inline changed the total text by %d bytes", total)
+	}
+	if k := (RecKey{Name: "fmt.Println", Package: "fmt", What: "text"}); moved[k] <= 0 {
+		t.Errorf("--inline moved %d bytes to fmt.Println; want some", moved[k])
+	}
+
+	// Each inlined function has one row, plus its own, if it
+	// wasn't always inlined.
+	out, _ = runShotizam(t, "--mode=json", "--inline", path)
+	var recs []Rec
+	if err := json.Unmarshal(out, &recs); err != nil {
+		t.Fatal(err)
+	}
+	n := map[RecKey]int{}
+	for _, r := range recs {
+		if r.What == "text" {
+			n[r.RecKey]++
+		}
+	}
+	for k, n := range n {
+		if n > 2 {
+			t.Errorf("%d text rows for %+v; want at most 2", n, k)
+		}
+	}
+}
+
+func TestFuncTextSizes(t *testing.T) {
+	for _, goos := range []string{"linux", "windows", "darwin"} {
+		b := buildTestProg(t, goos, "amd64")
+		f, err := Open(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%s: Open: %v", goos, err)
+		}
+		tab, err := gosym.NewTable(nil, gosym.NewLineTable(f.Gopclntab, f.TextOffset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var text int64
+		for i := range tab.Funcs {
+			text += f.funcTextSize(&tab.Funcs[i])
+		}
+		// The text section may also have non-Go code and
+		// padding.
+		if sect := int64(f.TextEnd - f.TextOffset); text > sect || text < sect-sect/100 {
+			t.Errorf("%s: function text totals %d bytes; want just under the text section's %d", goos, text, sect)
+		}
+	}
+
+	// A misread end of text is clamped.
+	f := &File{TextOffset: 0x1000, TextEnd: 0x2000}
+	if got := f.funcTextSize(&gosym.Func{Entry: 0x1f00, End: 0xffff0000}); got != 0x100 {
+		t.Errorf("last function text = %#x; want %#x", got, 0x100)
+	}
+}
+
+func TestGoStrings(t *testing.T) {
+	for _, goos := range []string{"linux", "darwin", "windows"} {
+		b := buildTestProg(t, goos, "amd64")
+		f, err := Open(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%s: Open: %v", goos, err)
+		}
+		byPkg, bySection := f.dataSizes(f.Strings)
+		if byPkg[""] == 0 || len(bySection) != 1 {
+			t.Errorf("%s: strings by package %v, by section %v; want some in one section", goos, byPkg, bySection)
+		}
+	}
+
+	f := &File{DataSections: []SectionSize{{Name: ".rodata", Addr: 0x1000, Size: 0x1000}}}
+	got := f.goStrings([]Sym{
+		{Name: "runtime.x", Addr: 0x1800, Size: 8},
+		{Name: "go:string.*", Addr: 0x1000},
+		{Name: "go.string.\"hi\"", Addr: 0x1900, Size: 2},
+	})
+	want := []Var{{"go:string.*", 0x1000, 0x800}, {"go.string.\"hi\"", 0x1900, 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("goStrings = %+v; want %+v", got, want)
+	}
+}
+
+func TestGoItabs(t *testing.T) {
+	f := &File{DataSections: []SectionSize{{Name: ".rodata", Addr: 0x1000, Size: 0x1000}}}
+	got := f.goItabs([]Sym{
+		{Name: "go.itab.*example.com/a/b.T,io.Reader", Addr: 0x1100, Size: 32},
+		{Name: "go:itab.main.S,fmt.Stringer", Addr: 0x1200}, // Mach-O: no size
+		{Name: "runtime.x", Addr: 0x1228, Size: 8},
+	})
+	want := []Var{{"*example.com/a/b.T,io.Reader", 0x1100, 32}, {"main.S,fmt.Stringer", 0x1200, 0x28}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("goItabs = %+v; want %+v", got, want)
+	}
+	for i, pkg := range []string{"example.com/a/b", "main"} {
+		if got := itabPackage(want[i].Name); got != pkg {
+			t.Errorf("itabPackage(%q) = %q; want %q", want[i].Name, got, pkg)
+		}
+	}
+}
+
+func TestGoTypes(t *testing.T) {
+	f := &File{DataSections: []SectionSize{{Name: ".go.type", Addr: 0x1000, Size: 0x1000}}}
+	got := f.dataSyms([]Sym{
+		{Name: "type:*", Addr: 0x1000}, // newer Go releases: a sizeless marker
+		{Name: "type:.eq.main.T", Addr: 0x1800, Size: 16},
+		{Name: "type.*example.com/a/b.T", Addr: 0x1900, Size: 56},
+	}, isTypeSym)
+	want := []Var{{"type:*", 0x1000, 0x800}, {"type.*example.com/a/b.T", 0x1900, 56}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("dataSyms = %+v; want %+v", got, want)
+	}
+	for name, pkg := range map[string]string{
+		"type:*":                      "",
+		"type:[]int":                  "",
+		"type:map[string]main.T":      "",
+		"type:*main.T":                "main",
+		"type.*example.com/a/b.T":     "example.com/a/b",
+		"type:example.com/a/b.T[int]": "example.com/a/b",
+	} {
+		if got := typePackage(name); got != pkg {
+			t.Errorf("typePackage(%q) = %q; want %q", name, got, pkg)
+		}
+	}
+}
+
+func TestBuildID(t *testing.T) {
+	for _, goos := range []string{"linux", "darwin", "windows"} {
+		b := buildTestProg(t, goos, "amd64")
+		f, err := Open(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%s: Open: %v", goos, err)
+		}
+		path := filepath.Join(t.TempDir(), "prog")
+		if err := os.WriteFile(path, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		out, err := exec.Command("go", "tool", "buildid", path).Output()
+		if err != nil {
+			t.Fatalf("%s: go tool buildid: %v", goos, err)
+		}
+		if want := strings.TrimSpace(string(out)); f.BuildID != want {
+			t.Errorf("%s: BuildID = %q; want %q", goos, f.BuildID, want)
+		}
+	}
+}
+
+// sqlDBTestRows returns rows for the --sqldb writer's test,
+// including a long one. Their columns are Func, Pkg, What, Size,
+// Ratio, and Even.
+func sqlDBTestRows() [][]any {
+	var rows [][]any
+	for i := 0; i < 20000; i++ {
+		rows = append(rows, []any{"main.f" + strconv.Itoa(i), "main", "text", int64(i - 100), nil, i%2 == 0})
+	}
+	rows[5000][0] = strings.Repeat("x", 10000)
+	rows[5001][4] = 0.5
+	return rows
+}
+
+const sqlDBTestSchema = "CREATE TABLE Bin (Func varchar, Pkg varchar, What varchar, Size int64, Ratio real, Even bool)"
+
+func TestWriteSQLDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := writeSQLDB(path, sqlDBTestSchema, sqlDBTestRows()); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var check string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&check); err != nil || check != "ok" {
+		t.Fatalf("integrity_check = %q, %v; want ok", check, err)
+	}
+	var count, size, maxLen, even int64
+	var ratio float64
+	if err := db.QueryRow("SELECT COUNT(*), SUM(Size), MAX(LENGTH(Func)), SUM(Ratio), SUM(Even) FROM Bin").Scan(&count, &size, &maxLen, &ratio, &even); err != nil {
+		t.Fatal(err)
+	}
+	if count != 20000 || size != 197990000 || maxLen != 10000 || ratio != 0.5 || even != 10000 {
+		t.Errorf("got %d rows, size %d, max len %d, ratio %v, %d even; want 20000, 197990000, 10000, 0.5, 10000", count, size, maxLen, ratio, even)
+	}
+	if ents, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".*")); len(ents) != 0 {
+		t.Errorf("temp files left behind: %q", ents)
+	}
+}
+
+// TestSQLDBFlag tests that --sqldb writes a database of the binary's
+// rows, and refuses --out, which it would ignore.
+func TestSQLDBFlag(t *testing.T) {
+	b := buildTestProg(t, "linux", "amd64")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prog")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(dir, "out.db")
+	runShotizam(t, "--sqldb="+dbPath, path)
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var sum int64
+	if err := db.QueryRow("SELECT SUM(Size) FROM Bin").Scan(&sum); err != nil {
+		t.Fatal(err)
+	}
+	if sum != int64(len(b)) {
+		t.Errorf("SUM(Size) = %d; want file size %d", sum, len(b))
+	}
+
+	cmd := exec.Command(os.Args[0], "--sqldb="+dbPath, "--out="+filepath.Join(dir, "out.sql"), path)
+	cmd.Env = append(os.Environ(), runShotizamEnv+"=1")
+	if out, err := cmd.CombinedOutput(); err == nil || !bytes.Contains(out, []byte("--out")) {
+		t.Errorf("shotizam --sqldb --out = %v; want --out error\n%s", err, out)
+	}
+}
+
+// TestSQLiteInProcess tests --sqlite's --query and, without the
+// sqlite3 binary in $PATH, its prompt, which both use the SQLite
+// driver.
+func TestSQLiteInProcess(t *testing.T) {
+	b := buildTestProg(t, "linux", "amd64")
+	path := filepath.Join(t.TempDir(), "prog")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("Total\n%d\n", len(b))
+	out, _ := runShotizam(t, "--sqlite", "--sql-views", "--query=SELECT SUM(Size) AS Total FROM PkgTotals", path)
+	if string(out) != want {
+		t.Errorf("--query output = %q; want %q", out, want)
+	}
+
+	cmd := exec.Command(os.Args[0], "--sqlite", path)
+	cmd.Env = append(os.Environ(), runShotizamEnv+"=1", "PATH="+t.TempDir())
+	cmd.Stdin = strings.NewReader("SELECT COUNT(*) > 0 AS Any\nFROM Bin;\n\nSELECT SUM(Size)\n  AS Total FROM Bin;\n.quit\nSELECT 1;\n")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("shotizam --sqlite: %v", err)
+	}
+	if got, want := string(out), "Any\n1\n"+want; got != want {
+		t.Errorf("prompt output = %q; want %q", got, want)
+	}
+}
+
+func TestPprof(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	pb := newPprofBuilder()
+	pb.add(nil, "main.f", "main", "text", 100)
+	pb.add(nil, "main.f", "main", "text", 20)
+	pb.add(nil, "main.f", "main", "pcln", 7)
+	pb.add(nil, "", "", "section:.data", 50)
+	pb.add(nil, "", "", "not-in-file", -10)
+	var buf bytes.Buffer
+	if err := pb.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "prof.pb.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(goTool, "tool", "pprof", "-top", "-nodecount=10", path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("pprof: %v\n%s", err, out)
+	}
+	// The total is the sum of the positive sizes, and main.f's
+	// samples are under the main package.
+	if !strings.Contains(string(out), "of 177B total") {
+		t.Errorf("pprof output lacks total of 177B:\n%s", out)
+	}
+	got := map[string]string{} // name => flat, cum
+	for _, line := range strings.Split(string(out), "\n") {
+		if f := strings.Fields(line); len(f) == 6 {
+			got[f[5]] = f[0] + " " + f[3]
+		}
+	}
+	for name, want := range map[string]string{
+		"main.f":        "127B 127B",
+		"main":          "0 127B",
+		"section:.data": "50B 50B",
+	} {
+		if got[name] != want {
+			t.Errorf("pprof flat and cum of %s = %q; want %q\n%s", name, got[name], want, out)
+		}
+	}
+}
+
+func TestFlameGraph(t *testing.T) {
+	tb := newTreeBuilder(nil, flameGraphLevels)
+	tb.root.Name = "prog"
+	tb.add(nil, "main.f", "main", "text", 100)
+	tb.add(nil, "main.</script>", "main", "text", 10)
+	var buf bytes.Buffer
+	if err := writeFlameGraph(&buf, "prog", tb.root); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"<title>prog - shotizam</title>",
+		`const root = {"name":"prog","children":[{"name":"main","children":[{"name":"main.f","size":100},{"name":"main.\u003c/script\u003e","size":10}]}]};`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output lacks %q", want)
+		}
+	}
+	if n := strings.Count(got, "</script>"); n != 1 {
+		t.Errorf("output has %d </script> tags; want 1", n)
+	}
+}
+
+func TestTreemap(t *testing.T) {
+	tb := newTreeBuilder(nil, "pkg,func,what")
+	tb.root.Name = "prog"
+	tb.add(nil, "main.f", "main", "text", 100)
+	tb.add(nil, "main.f", "main", "pcln", 20)
+	tb.add(nil, "main.g", "main", "text", 200)
+	var buf bytes.Buffer
+	if err := writeTreemap(&buf, "prog", tb.root); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"<title>prog - shotizam</title>",
+		`const root = {"name":"prog","children":[{"name":"main","children":[{"name":"main.g","children":[{"name":"text","size":200}]},{"name":"main.f","children":[{"name":"text","size":100},{"name":"pcln","size":20}]}]}]};`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output lacks %q", want)
+		}
+	}
+}
+
+// parquetTestCols and parquetTestRows are the Bin table for the
+// parquet mode's tests.
+var (
+	parquetTestCols = []dbColumn{{"Func", "varchar"}, {"Pkg", "varchar"}, {"What", "varchar"}, {"Size", "int64"}, {"Ratio", "real"}, {"Even", "bool"}}
+	parquetTestRows = [][]any{
+		{"main.main", "main", "text", int64(120), 0.5, true},
+		{"runtime.gc", "runtime", "pcln", -7, nil, false},
+		{nil, nil, "section:.data", int64(4096), 1.25, nil},
+	}
+)
+
+func TestWriteParquet(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeParquet(&buf, parquetTestCols, parquetTestRows); err != nil {
+		t.Fatal(err)
+	}
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, path := range f.Schema().Columns() {
+		names = append(names, strings.Join(path, "."))
+	}
+	if want := []string{"Func", "Pkg", "What", "Size", "Ratio", "Even"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("columns = %q; want %q", names, want)
+	}
+	rows := make([]parquet.Row, len(parquetTestRows)+1)
+	n, err := parquet.NewReader(f).ReadRows(rows)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if n != len(parquetTestRows) {
+		t.Fatalf("read %d rows; want %d", n, len(parquetTestRows))
+	}
+	for i, row := range rows[:n] {
+		var got []any
+		for _, v := range row {
+			switch {
+			case v.IsNull():
+				got = append(got, nil)
+			case v.Kind() == parquet.ByteArray:
+				got = append(got, v.String())
+			case v.Kind() == parquet.Int64:
+				got = append(got, v.Int64())
+			case v.Kind() == parquet.Double:
+				got = append(got, v.Double())
+			case v.Kind() == parquet.Boolean:
+				got = append(got, v.Boolean())
+			}
+		}
+		want := slices.Clone(parquetTestRows[i])
+		if n, ok := want[3].(int); ok {
+			want[3] = int64(n)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("row %d = %v; want %v", i, got, want)
+		}
+	}
+}
+
+// TestParquetPyarrow tests that pyarrow, another Parquet reader,
+// reads writeParquet's output.
+func TestParquetPyarrow(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	if err := exec.Command(python, "-c", "import pyarrow.parquet").Run(); err != nil {
+		t.Skip("pyarrow not installed")
+	}
+	var buf bytes.Buffer
+	if err := writeParquet(&buf, parquetTestCols, parquetTestRows); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bin.parquet")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	const script = `
+import json, sys
+import pyarrow.parquet as pq
+t = pq.read_table(sys.argv[1])
+print(json.dumps({"schema": [[f.name, str(f.type), f.nullable] for f in t.schema], "rows": t.to_pylist()}))
+`
+	out, err := exec.Command(python, "-c", script, path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("pyarrow: %v\n%s", err, out)
+	}
+	var got struct {
+		Schema [][]any
+		Rows   []map[string]any
+	}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("decoding pyarrow output %q: %v", out, err)
+	}
+	wantSchema := [][]any{
+		{"Func", "string", true}, {"Pkg", "string", true}, {"What", "string", true},
+		{"Size", "int64", true}, {"Ratio", "double", true}, {"Even", "bool", true},
+	}
+	if !reflect.DeepEqual(got.Schema, wantSchema) {
+		t.Errorf("schema = %v; want %v", got.Schema, wantSchema)
+	}
+	var wantRows []map[string]any
+	for _, r := range parquetTestRows {
+		m := map[string]any{}
+		for i, c := range parquetTestCols {
+			v := r[i]
+			switch n := v.(type) {
+			case int:
+				v = float64(n) // as decoded from JSON
+			case int64:
+				v = float64(n)
+			}
+			m[c.name] = v
+		}
+		wantRows = append(wantRows, m)
+	}
+	if !reflect.DeepEqual(got.Rows, wantRows) {
+		t.Errorf("rows = %v; want %v", got.Rows, wantRows)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	sizes := map[RecKey]int64{
+		{Package: "runtime", What: "text"}: 1234567,
+		{Package: "main", What: "text"}:    100,
+		{Package: "", What: "rtti"}:        5000,
+		{Package: "a|b", What: "pcln"}:     20,
+		{Package: "main", What: "pcln"}:    10,
+	}
+	var buf bytes.Buffer
+	writeMarkdown(&buf, sizes, 3)
+	want := `| Package | What | Size |
+|---|---|--:|
+| runtime | text | 1,234,567 |
+| (none) | rtti | 5,000 |
+| main | text | 100 |
+| (2 more) | | 30 |
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	writeMarkdown(&buf, sizes, 0)
+	if !strings.Contains(buf.String(), `| a\|b | pcln | 20 |`) {
+		t.Errorf("missing escaped row in:\n%s", buf.String())
+	}
+}
+
+func TestGroupDigits(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:        "0",
+		999:      "999",
+		1000:     "1,000",
+		-123456:  "-123,456",
+		12345678: "12,345,678",
+	} {
+		if got := groupDigits(n); got != want {
+			t.Errorf("groupDigits(%d) = %q; want %q", n, got, want)
+		}
+	}
+}
+
+func TestTreeServer(t *testing.T) {
+	tree := newTreeBuilder(nil, "pkg,func")
+	tree.add(nil, "main.f", "main", "text", 100)
+	tree.add(nil, "fmt.Println", "fmt", "text", 2000)
+	flame := newTreeBuilder(nil, flameGraphLevels)
+	flame.add(nil, "main.f", "main", "text", 100)
+	cols := binColumns(false)
+	rows := [][]any{{"main.f", "main", "text", int64(100)}, {nil, nil, "TODO", int64(5)}}
+	srv, err := newTreeServer("prog", tree.root, flame.root, cols, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		res, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var buf bytes.Buffer
+		buf.ReadFrom(res.Body)
+		return res.StatusCode, buf.String()
+	}
+	for _, tt := range []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/", 200, `<td>fmt</td><td class="size">2,000</td>`},
+		{"/treemap.json", 200, `{"name":"root","children":[{"name":"fmt","children":[{"name":"fmt.Println","size":2000}]},{"name":"main","children":[{"name":"main.f","size":100}]}]}`},
+		{"/treemap.json?pkg=main", 200, `{"name":"main","children":[{"name":"main.f","size":100}]}`},
+		{"/flamegraph?pkg=main", 200, "<title>prog main - shotizam</title>"},
+		{"/treemap?pkg=nope", 404, "no such package"},
+		{"/bin.json", 200, `{"Func":null,"Pkg":null,"What":"TODO","Size":5}`},
+		{"/nope", 404, "not found"},
+	} {
+		status, body := get(tt.path)
+		if status != tt.status || !strings.Contains(body, tt.want) {
+			t.Errorf("GET %s = %d, %q; want %d, containing %q", tt.path, status, body, tt.status, tt.want)
+		}
+	}
+}
+
+func TestWriteBinJSON(t *testing.T) {
+	cols := []dbColumn{{"Func", "varchar"}, {"Size", "int64"}, {"Leaf", "bool"}}
+	rows := [][]any{
+		{"main.f", int64(10), true},
+		{nil, int64(-3), nil},
+	}
+	var buf bytes.Buffer
+	if err := writeBinJSON(&buf, cols, rows); err != nil {
+		t.Fatal(err)
+	}
+	want := `[
+{"Func":"main.f","Size":10,"Leaf":true},
+{"Func":null,"Size":-3,"Leaf":null}
+]
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSQLIndexesAndViews(t *testing.T) {
+	sqlite3, err := exec.LookPath("sqlite3")
+	if err != nil {
+		t.Skip("sqlite3 not found")
+	}
+	for _, multiArch := range []bool{false, true} {
+		arch := ""
+		if multiArch {
+			arch = ", Arch varchar"
+		}
+		sql := "CREATE TABLE Bin (Func varchar, Pkg varchar, What varchar, Size int64" + arch + ");\n"
+		if multiArch {
+			sql += "INSERT INTO Bin VALUES ('main.f', 'main', 'text', 10, 'amd64'), ('main.f', 'main', 'pcln', 5, 'amd64'), ('', 'main', 'var', 1, 'arm64');\n"
+		} else {
+			sql += "INSERT INTO Bin VALUES ('main.f', 'main', 'text', 10), ('main.f', 'main', 'pcln', 5), ('', 'main', 'var', 1);\n"
+		}
+		// Twice, as when loading into an existing database.
+		sql += sqlIndexesAndViews(multiArch) + sqlIndexesAndViews(multiArch)
+		sql += "SELECT * FROM PkgTotals; SELECT * FROM WhatTotals LIMIT 1; SELECT * FROM FuncTotals;\n"
+		cmd := exec.Command(sqlite3, ":memory:")
+		cmd.Stdin = strings.NewReader(sql)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("sqlite3: %v\n%s", err, out)
+		}
+		want := "main|16\ntext|10\nmain.f|main|15\n"
+		if multiArch {
+			want = "amd64|main|15\narm64|main|1\namd64|text|10\namd64|main.f|main|15\n"
+		}
+		if got := string(out); got != want {
+			t.Errorf("multiArch=%v: sqlite3 output = %q; want %q", multiArch, got, want)
+		}
+	}
+}
+
+func TestWriteTop(t *testing.T) {
+	sizes := map[string]int64{
+		"runtime": 6000,
+		"":        3000,
+		"fmt":     500,
+		"main":    300,
+		"os":      200,
+	}
+	var buf bytes.Buffer
+	writeTop(&buf, sizes, 10000, 3, false)
+	want := ` 6000 (60.00%) runtime
+ 3000 (30.00%) (none)
+  500 ( 5.00%) fmt
+  500 ( 5.00%) (2 more)
+10000 (100.00%) total
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	writeTop(&buf, map[string]int64{"runtime": 50, "os": 1}, 1000, 0, true)
+	want = "  50 \x1b[33m( 5.00%)\x1b[0m runtime\n   1 ( 0.10%) os\n1000 (100.00%) \x1b[1mtotal\x1b[0m\n"
+	if got := buf.String(); got != want {
+		t.Errorf("with color, got %q; want %q", got, want)
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	v := []any{
+		Rec{RecKey: RecKey{Name: "main.f", Package: "main", What: "text"}, Size: 10, Cols: []sizes.Col{{Name: "asm", Value: true}, {Name: "metaratio", Value: 0.5}}},
+		map[string]any{"nested": []any{[]any{1, "yes"}, map[string]any{}}, "empty": []any{}, "a b": nil},
+		"null",
+	}
+	var buf bytes.Buffer
+	if err := writeYAML(&buf, v); err != nil {
+		t.Fatal(err)
+	}
+	want := `- name: "main.f"
+  package: "main"
+  what: "text"
+  size: 10
+  asm: true
+  metaratio: 0.5
+- a b: null
+  empty: []
+  nested:
+    - - 1
+      - "yes"
+    - {}
+- "null"
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := writeYAML(&buf, 42); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "42\n" {
+		t.Errorf("scalar: got %q; want %q", got, "42\n")
+	}
+}
+
+func TestWriteTrace(t *testing.T) {
+	tb := newTreeBuilder(nil, traceLevels)
+	tb.root.Name = "prog"
+	tb.add(nil, "main.f", "main", "text", 100)
+	tb.add(nil, "main.f", "main", "pcln", 10)
+	tb.add(nil, "fmt.Println", "fmt", "text", 200)
+	var buf bytes.Buffer
+	if err := writeTrace(&buf, "prog", tb.root); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"traceEvents":[` +
+		`{"name":"process_name","ph":"M","ts":0,"pid":1,"tid":1,"args":{"name":"prog"}},` +
+		`{"name":"prog","ph":"X","ts":0,"dur":310,"pid":1,"tid":1,"args":{"bytes":310}},` +
+		`{"name":"fmt","ph":"X","ts":0,"dur":200,"pid":1,"tid":1,"args":{"bytes":200}},` +
+		`{"name":"fmt.Println","ph":"X","ts":0,"dur":200,"pid":1,"tid":1,"args":{"bytes":200}},` +
+		`{"name":"text","ph":"X","ts":0,"dur":200,"pid":1,"tid":1,"args":{"bytes":200}},` +
+		`{"name":"main","ph":"X","ts":200,"dur":110,"pid":1,"tid":1,"args":{"bytes":110}},` +
+		`{"name":"main.f","ph":"X","ts":200,"dur":110,"pid":1,"tid":1,"args":{"bytes":110}},` +
+		`{"name":"text","ph":"X","ts":200,"dur":100,"pid":1,"tid":1,"args":{"bytes":100}},` +
+		`{"name":"pcln","ph":"X","ts":300,"dur":10,"pid":1,"tid":1,"args":{"bytes":10}}` +
+		`],"displayTimeUnit":"ns"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	root := &pkgTree{name: "prog"}
+	root.add(nil, "", "github.com/foo/bar", "var", 300)
+	root.add(nil, "", "github.com/foo/bar", "rtti", 100)
+	root.add(nil, "", "fmt", "var", 50)
+	root.add(nil, "go:itab.*os.File,io.Writer", "os", "itab", 40)
+	root.add(nil, "", "slices..dict.f[internal/fmtsort", "var", 8)
+	root.add(nil, "", "", "dwarf:.debug_info", 500)
+	var buf bytes.Buffer
+	writeTree(&buf, root, 0)
+	want := `998 (100.00%) prog
+500 ( 50.10%)   dwarf:.debug_info
+400 ( 40.08%)   github.com/foo/bar
+300 ( 30.06%)     var
+100 ( 10.02%)     rtti
+ 50 (  5.01%)   fmt var
+ 40 (  4.01%)   os itab go:itab.*os.File,io.Writer
+  8 (  0.80%)   slices..dict.f[internal/fmtsort var
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	writeTree(&buf, root, 2)
+	want = `998 (100.00%) prog
+500 ( 50.10%)   dwarf:.debug_info
+400 ( 40.08%)   github.com/foo/bar
+300 ( 30.06%)     var
+100 ( 10.02%)     rtti
+ 98 (  9.82%)   (3 more)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("with top 2, got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteProm(t *testing.T) {
+	sizes := map[RecKey]int64{
+		{Package: "runtime", What: "text"}: 100,
+		{Package: "", What: "not-in-file"}: -20,
+		{Package: `a"b\c`, What: "var"}:    5,
+	}
+	var buf bytes.Buffer
+	writeProm(&buf, "prog", sizes, 85)
+	want := `# HELP go_binary_size_bytes Bytes of a Go binary by package and what they're for.
+# TYPE go_binary_size_bytes gauge
+go_binary_size_bytes{binary="prog",package="",what="not-in-file"} -20
+go_binary_size_bytes{binary="prog",package="a\"b\\c",what="var"} 5
+go_binary_size_bytes{binary="prog",package="runtime",what="text"} 100
+# HELP go_binary_file_size_bytes Size of a Go binary's file.
+# TYPE go_binary_file_size_bytes gauge
+go_binary_file_size_bytes{binary="prog"} 85
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sizes contains the size records of shotizam's json
+// output and the diffing of them, for programs that compare the
+// sizes of binaries without running shotizam's command.
+package sizes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+type RecKey struct {
+	Name    string `json:"name,omitempty"`
+	Package string `json:"package,omitempty"`
+	What    string `json:"what"`
+	Arch    string `json:"arch,omitempty"` // only with --arch=all
+}
+
+type Rec struct {
+	RecKey
+	Size int64 `json:"size"`
+
+	// Cols are the record's optional columns, as selected by
+	// shotizam's --columns flag. They're not part of diffs.
+	Cols []Col `json:"-"`
+}
+
+// Col is an optional column of a Rec.
+type Col struct {
+	Name  string // the JSON field name
+	Value any    // or nil to omit the field
+}
+
+// MarshalJSON encodes r as an object with its optional columns
+// as additional fields.
+func (r Rec) MarshalJSON() ([]byte, error) {
+	type plainRec Rec // without the MarshalJSON method
+	b, err := json.Marshal(plainRec(r))
+	if err != nil || len(r.Cols) == 0 {
+		return b, err
+	}
+	buf := bytes.NewBuffer(b[:len(b)-1]) // without the closing brace
+	for _, c := range r.Cols {
+		if c.Value == nil {
+			continue
+		}
+		vb, err := json.Marshal(c.Value)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(buf, ",%q:%s", c.Name, vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// recMap returns the total size of recs by key. Keys needn't be
+// unique: shotizam emits several records with the same key, such as
+// one per ABI wrapper of a function, or with --inline, a function's
+// own text and the text of its inlined calls, and their sizes add up.
+func recMap(recs []Rec) map[RecKey]int64 {
+	m := make(map[RecKey]int64)
+	for _, r := range recs {
+		m[r.RecKey] += r.Size
+	}
+	return m
+}
+
+// Diff returns the size change of each record going from a to b.
+//
+// Records with the same key are summed. Records present in both a
+// and b are reported with their size delta, records only in b are
+// reported with their full size, and records only in a are reported
+// as negative sizes. Records whose size didn't change are omitted.
+// The result is sorted by size, smallest (most negative) first.
+// Neither a nor b is modified.
+func Diff(a, b []Rec) []Rec {
+	am, bm := recMap(a), recMap(b)
+	diff := make(map[RecKey]int64)
+	for k, size := range bm {
+		if change := size - am[k]; change != 0 {
+			diff[k] = change
+		}
+	}
+	// Anything only in a is stuff we dropped. Count it as
+	// negative size.
+	for k, size := range am {
+		if _, ok := bm[k]; !ok && size != 0 {
+			diff[k] = -size
+		}
+	}
+
+	recs := make([]Rec, 0, len(diff))
+	for k, size := range diff {
+		recs = append(recs, Rec{RecKey: k, Size: size})
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].Size != recs[j].Size {
+			return recs[i].Size < recs[j].Size
+		}
+		return recs[i].RecKey.less(recs[j].RecKey)
+	})
+	return recs
+}
+
+func (k RecKey) less(o RecKey) bool {
+	if k.Name != o.Name {
+		return k.Name < o.Name
+	}
+	if k.Package != o.Package {
+		return k.Package < o.Package
+	}
+	if k.What != o.What {
+		return k.What < o.What
+	}
+	return k.Arch < o.Arch
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sizes
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	key := func(name, what string) RecKey { return RecKey{Name: name, Package: "main", What: what} }
+	a := []Rec{
+		{RecKey: key("main.a", "text"), Size: 100},
+		{RecKey: key("main.b", "text"), Size: 50},
+		{RecKey: key("main.c", "text"), Size: 10},
+	}
+	b := []Rec{
+		{RecKey: key("main.a", "text"), Size: 120},
+		{RecKey: key("main.c", "text"), Size: 10},
+		{RecKey: key("main.d", "text"), Size: 30},
+	}
+	got := Diff(a, b)
+	want := []Rec{
+		{RecKey: key("main.b", "text"), Size: -50},
+		{RecKey: key("main.a", "text"), Size: 20},
+		{RecKey: key("main.d", "text"), Size: 30},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %+v; want %+v", got, want)
+	}
+	if len(a) != 3 || len(b) != 3 {
+		t.Errorf("Diff modified its inputs")
+	}
+}
+
+func TestDiffDuplicateKeys(t *testing.T) {
+	key := RecKey{Name: "main.f", Package: "main", What: "text"}
+	a := []Rec{{RecKey: key, Size: 10}, {RecKey: key, Size: 5}}
+	b := []Rec{{RecKey: key, Size: 10}, {RecKey: key, Size: 7}}
+	got := Diff(a, b)
+	want := []Rec{{RecKey: key, Size: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %+v; want %+v", got, want)
+	}
+}
+
+func TestRecMarshalJSON(t *testing.T) {
+	r := Rec{
+		RecKey: RecKey{Name: "main.f", Package: "main", What: "text"},
+		Size:   10,
+		Cols:   []Col{{Name: "asm", Value: true}, {Name: "startline", Value: nil}, {Name: "metaratio", Value: 0.5}},
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"name":"main.f","package":"main","what":"text","size":10,"asm":true,"metaratio":0.5}`
+	if string(b) != want {
+		t.Errorf("got %s; want %s", b, want)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	_ "modernc.org/sqlite" // pure Go, so no sqlite3 binary or cgo is needed
+)
+
+// writeSQLDB writes the SQLite database file path for the --sqldb
+// flag: the Bin table, created by the SQL statement schema, holding
+// rows. The file is replaced atomically.
+func writeSQLDB(path, schema string, rows [][]any) error {
+	af, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	defer af.abort()
+	db, err := loadSQLDB(af.Name(), schema, rows)
+	if err != nil {
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+	return af.Close()
+}
+
+// loadSQLDB opens the SQLite database named by dsn, which may be
+// ":memory:", creates the Bin table in it with schema, and inserts
+// rows into it.
+func loadSQLDB(dsn, schema string, rows [][]any) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// Each connection to ":memory:" is a separate database.
+	db.SetMaxOpenConns(1)
+	if err := fillSQLDB(db, schema, rows); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func fillSQLDB(db *sql.DB, schema string, rows [][]any) error {
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	params := strings.TrimSuffix(strings.Repeat("?, ", len(rows[0])), ", ")
+	ins, err := tx.Prepare("INSERT INTO Bin VALUES (" + params + ")")
+	if err != nil {
+		return err
+	}
+	defer ins.Close()
+	for _, row := range rows {
+		if _, err := ins.Exec(row...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// runSQL runs the SQL statement query in db, writing any resulting
+// rows to w as aligned columns under a header, like sqlite3's
+// -header -column mode. NULLs are written as empty fields.
+func runSQL(w io.Writer, db *sql.DB, query string) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil || len(cols) == 0 {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(cols, "\t"))
+	vals := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		for i, v := range vals {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, tsvValue(v))
+		}
+		fmt.Fprintln(tw)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+// sqlPrompt reads SQL statements from r, each ending with a
+// semicolon, running them in db with runSQL until r's EOF or a
+// .quit. It's the --sqlite prompt when the sqlite3 binary isn't
+// installed, so errors are reported to stderr without stopping.
+// The prompt is only shown if r is a terminal.
+func sqlPrompt(w io.Writer, r *os.File, db *sql.DB) error {
+	fi, err := r.Stat()
+	interactive := err == nil && fi.Mode()&os.ModeCharDevice != 0
+	prompt := func(s string) {
+		if interactive {
+			fmt.Fprint(w, s)
+		}
+	}
+	if interactive {
+		fmt.Fprintln(w, "shotizam SQLite prompt; end statements with \";\", and .quit to exit")
+	}
+	var stmt strings.Builder
+	sc := bufio.NewScanner(r)
+	for prompt("sqlite> "); sc.Scan(); {
+		line := strings.TrimSpace(sc.Text())
+		if stmt.Len() == 0 {
+			if line == ".quit" || line == ".exit" {
+				return nil
+			}
+			if strings.HasPrefix(line, ".") {
+				fmt.Fprintf(os.Stderr, "unsupported command %s; without the sqlite3 binary, only SQL and .quit are supported\n", line)
+				line = ""
+			}
+			if line == "" {
+				prompt("sqlite> ")
+				continue
+			}
+		}
+		stmt.WriteString(sc.Text())
+		stmt.WriteByte('\n')
+		if !strings.HasSuffix(line, ";") {
+			prompt("   ...> ")
+			continue
+		}
+		if err := runSQL(w, db, stmt.String()); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		stmt.Reset()
+		prompt("sqlite> ")
+	}
+	return sc.Err()
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// ANSI terminal escapes for the top mode's colors.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+)
+
+// writeTop writes the top mode's output: the top largest packages of
+// pkgSize, ranked, with their percentage of total, formatted like
+// cmd/oldgosize's printSortedMap. If top is positive, only the top
+// largest are listed, followed by a line with the total of the rest.
+// With color, packages with large shares are highlighted.
+func writeTop(w io.Writer, pkgSize map[string]int64, total int64, top int, color bool) {
+	pkgs := make([]string, 0, len(pkgSize))
+	for pkg := range pkgSize {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		if pkgSize[pkgs[i]] != pkgSize[pkgs[j]] {
+			return pkgSize[pkgs[i]] > pkgSize[pkgs[j]]
+		}
+		return pkgs[i] < pkgs[j]
+	})
+	width := len(strconv.FormatInt(total, 10))
+	paint := func(c, s string) string {
+		if !color || c == "" {
+			return s
+		}
+		return c + s + ansiReset
+	}
+	line := func(size int64, name, nameColor string) {
+		pct := float64(size) * 100 / float64(total)
+		var pctColor string
+		switch {
+		case pct >= 10:
+			pctColor = ansiRed
+		case pct >= 1:
+			pctColor = ansiYellow
+		}
+		fmt.Fprintf(w, "%*d %s %s\n", width, size,
+			paint(pctColor, fmt.Sprintf("(%5.02f%%)", pct)), paint(nameColor, name))
+	}
+	var rest int64
+	for i, pkg := range pkgs {
+		if top > 0 && i >= top {
+			rest += pkgSize[pkg]
+			continue
+		}
+		if pkg == "" {
+			line(pkgSize[pkg], "(none)", ansiDim)
+			continue
+		}
+		line(pkgSize[pkg], pkg, "")
+	}
+	if top > 0 && len(pkgs) > top {
+		line(rest, fmt.Sprintf("(%d more)", len(pkgs)-top), ansiDim)
+	}
+	fmt.Fprintf(w, "%*d (100.00%%) %s\n", width, total, paint(ansiBold, "total"))
+}
+
+// isTerminal reports whether f is a terminal, and NO_COLOR
+// (https://no-color.org) isn't set, for writing colors to.
+func isTerminal(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
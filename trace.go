@@ -0,0 +1,64 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// traceLevels are the levels of the trace mode's tree.
+const traceLevels = "pkg,func,what"
+
+// A traceEvent is an event of the Trace Event Format read by
+// chrome://tracing and Perfetto
+// (https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU).
+type traceEvent struct {
+	Name  string         `json:"name"`
+	Phase string         `json:"ph"`
+	TS    int64          `json:"ts"`
+	Dur   int64          `json:"dur,omitempty"`
+	PID   int            `json:"pid"`
+	TID   int            `json:"tid"`
+	Args  map[string]any `json:"args,omitempty"`
+}
+
+// writeTrace writes the trace mode's output: the tree rooted at root,
+// of the binary titled title, as a trace of nested complete events,
+// so the trace viewers' flame charts draw it like a flame graph.
+// Each node's event lasts a microsecond per byte of its size and
+// starts where the previous sibling's ended.
+func writeTrace(w io.Writer, title string, root *TreeNode) error {
+	root.sortBySize()
+	events := []traceEvent{{
+		Name:  "process_name",
+		Phase: "M",
+		PID:   1,
+		TID:   1,
+		Args:  map[string]any{"name": title},
+	}}
+	var add func(n *TreeNode, ts int64) int64
+	add = func(n *TreeNode, ts int64) int64 {
+		total := n.Total()
+		events = append(events, traceEvent{
+			Name:  n.Name,
+			Phase: "X",
+			TS:    ts,
+			Dur:   total,
+			PID:   1,
+			TID:   1,
+			Args:  map[string]any{"bytes": total},
+		})
+		for _, c := range n.Children {
+			ts += add(c, ts)
+		}
+		return total
+	}
+	add(root, 0)
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents     []traceEvent `json:"traceEvents"`
+		DisplayTimeUnit string       `json:"displayTimeUnit"`
+	}{events, "ns"})
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bradfitz/shotizam/gosym"
+)
+
+// A TreeNode is a node of the treemap-json output, in the
+// {name, children, size} shape that d3.hierarchy and similar treemap
+// libraries expect. Only leaves have a size; the size of an interior
+// node is the sum of its children's.
+type TreeNode struct {
+	Name     string      `json:"name"`
+	Size     int64       `json:"size,omitempty"`
+	Children []*TreeNode `json:"children,omitempty"`
+
+	kids map[string]*TreeNode // by Name
+}
+
+// child returns n's child named name, creating it if needed.
+func (n *TreeNode) child(name string) *TreeNode {
+	if c, ok := n.kids[name]; ok {
+		return c
+	}
+	if n.kids == nil {
+		n.kids = map[string]*TreeNode{}
+	}
+	c := &TreeNode{Name: name}
+	n.kids[name] = c
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// Total returns the total size of n and its descendants.
+func (n *TreeNode) Total() int64 {
+	sum := n.Size
+	for _, c := range n.Children {
+		sum += c.Total()
+	}
+	return sum
+}
+
+// sortBySize sorts n's descendants by size, largest first.
+func (n *TreeNode) sortBySize() {
+	totals := map[*TreeNode]int64{}
+	for _, c := range n.Children {
+		c.sortBySize()
+		totals[c] = c.Total()
+	}
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		return totals[n.Children[i]] > totals[n.Children[j]]
+	})
+}
+
+// treeLevels are the valid levels of a treeBuilder.
+var treeLevels = map[string]bool{
+	"pkg":  true,
+	"type": true,
+	"file": true,
+	"func": true,
+	"what": true,
+}
+
+// treeBuilder builds a tree of records grouped by a list of levels.
+type treeBuilder struct {
+	t      *gosym.Table
+	levels []string
+	root   *TreeNode
+
+	lastFn   *gosym.Func // cache for file lookups
+	lastFile string
+}
+
+// newTreeBuilder returns a treeBuilder grouping by levels, a
+// comma-separated list of level names.
+func newTreeBuilder(t *gosym.Table, levels string) *treeBuilder {
+	tb := &treeBuilder{t: t, root: &TreeNode{Name: "root"}}
+	for _, lv := range strings.Split(levels, ",") {
+		lv = strings.TrimSpace(lv)
+		if !treeLevels[lv] {
+			fatalf("unknown tree level %q", lv)
+		}
+		tb.levels = append(tb.levels, lv)
+	}
+	return tb
+}
+
+// add adds a record of size bytes, belonging to fn (which may be
+// nil), to the tree. Empty type and file levels are skipped.
+func (tb *treeBuilder) add(fn *gosym.Func, name, pkg, what string, size int64) {
+	n := tb.root
+	for _, lv := range tb.levels {
+		var key string
+		switch lv {
+		case "pkg":
+			key = pkg
+			if key == "" {
+				key = "(none)"
+			}
+		case "type":
+			if fn != nil {
+				key = fn.ReceiverName()
+			}
+		case "file":
+			key = tb.file(fn)
+		case "func":
+			key = name
+			if key == "" {
+				key = "(none)"
+			}
+		case "what":
+			key = what
+		}
+		if key == "" {
+			continue
+		}
+		n = n.child(key)
+	}
+	n.Size += size
+}
+
+// file returns the source file of fn, or the empty string.
+func (tb *treeBuilder) file(fn *gosym.Func) string {
+	if fn == nil {
+		return ""
+	}
+	if fn != tb.lastFn {
+		tb.lastFn = fn
+		tb.lastFile, _, _ = tb.t.PCToLine(fn.Entry)
+	}
+	return tb.lastFile
+}
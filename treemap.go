@@ -0,0 +1,27 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+)
+
+//go:embed treemap.html
+var treemapHTML string
+
+var treemapTmpl = template.Must(template.New("treemap").Parse(treemapHTML))
+
+// writeTreemap writes the treemap mode's output: a self-contained
+// HTML page drawing the tree rooted at root, titled title, as a
+// zoomable treemap.
+func writeTreemap(w io.Writer, title string, root *TreeNode) error {
+	root.sortBySize()
+	return treemapTmpl.Execute(w, struct {
+		Title string
+		Root  *TreeNode
+	}{title, root})
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bradfitz/shotizam/gosym"
+)
+
+// WebAssembly section IDs.
+// See https://webassembly.github.io/spec/core/binary/modules.html.
+const (
+	wasmSectionCode = 10
+	wasmSectionData = 11
+)
+
+// wasmFuncValueOffset is the PC_F value of a wasm binary's first
+// defined function. PC_F is wasmFuncValueOffset plus the function's
+// index (not counting imports), and is what the pclntab records as
+// the function's entry, relative to a text start of zero. See
+// cmd/link/internal/wasm.
+const wasmFuncValueOffset = 0x1000
+
+func isWasm(ra io.ReaderAt) bool {
+	var hdr [8]byte
+	_, err := ra.ReadAt(hdr[:], 0)
+	return err == nil && string(hdr[:]) == "\x00asm\x01\x00\x00\x00"
+}
+
+// wasmFile opens a WebAssembly module built by the Go toolchain
+// (GOARCH=wasm). The pclntab is found in the linear memory image
+// formed by the module's data segments. As wasm PCs aren't byte
+// offsets, function text sizes come from the code section instead.
+func wasmFile(ra io.ReaderAt, size int64) (*File, error) {
+	b := make([]byte, size)
+	if _, err := ra.ReadAt(b, 0); err != nil {
+		return nil, err
+	}
+	f := &File{Size: size, Arch: "wasm"}
+	var mem []byte
+	p := &wasmReader{b: b[8:]}
+	for p.err == nil && len(p.b) > 0 {
+		id := p.byte()
+		sect := &wasmReader{b: p.bytes(int(p.uleb()))}
+		switch id {
+		case wasmSectionCode:
+			for n := sect.uleb(); n > 0 && sect.err == nil; n-- {
+				bodySize := sect.uleb()
+				sect.bytes(int(bodySize))
+				f.wasmFuncSizes = append(f.wasmFuncSizes, int64(bodySize))
+			}
+		case wasmSectionData:
+			for n := sect.uleb(); n > 0 && sect.err == nil; n-- {
+				if mode := sect.uleb(); mode != 0 {
+					return nil, fmt.Errorf("unsupported wasm data segment mode %d", mode)
+				}
+				// The offset is a constant expression: i32.const N; end.
+				if op := sect.byte(); op != 0x41 {
+					return nil, fmt.Errorf("unsupported wasm data segment offset opcode %#x", op)
+				}
+				off := int(sect.uleb())
+				sect.byte() // end
+				seg := sect.bytes(int(sect.uleb()))
+				if sect.err != nil {
+					break
+				}
+				if end := off + len(seg); end > len(mem) {
+					mem = append(mem, make([]byte, end-len(mem))...)
+				}
+				copy(mem[off:], seg)
+			}
+		}
+		if sect.err != nil {
+			return nil, fmt.Errorf("wasm section %d: %w", id, sect.err)
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	// The pclntab header: a Go 1.18+ magic number, two zero bytes,
+	// a PC quantum of 1, and 8 byte pointers.
+	i := bytes.Index(mem, []byte("\xff\xff\xff\x00\x00\x01\x08"))
+	if i < 1 || (mem[i-1] != 0xf0 && mem[i-1] != 0xf1) {
+		return nil, errors.New("no pclntab found in wasm data segments")
+	}
+	f.Gopclntab = mem[i-1:]
+	return f, nil
+}
+
+// wasmReader reads the primitive types of the wasm binary format.
+// Errors are sticky.
+type wasmReader struct {
+	b   []byte
+	err error
+}
+
+func (r *wasmReader) byte() byte {
+	if b := r.bytes(1); len(b) == 1 {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *wasmReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.b) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+// uleb reads an unsigned LEB128 number. It also accepts the
+// non-negative signed LEB128 numbers used by i32.const.
+func (r *wasmReader) uleb() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errors.New("bad LEB128 number")
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+// wasmFuncSize returns the size of fn's body, if f is a wasm
+// module, whose pclntab's PCs aren't code addresses.
+func (f *File) wasmFuncSize(fn *gosym.Func) (size int64, ok bool) {
+	if f.wasmFuncSizes == nil {
+		return 0, false
+	}
+	if i := int(fn.Entry) - wasmFuncValueOffset; i >= 0 && i < len(f.wasmFuncSizes) {
+		return f.wasmFuncSizes[i], true
+	}
+	return 0, true
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Brad Fitzpatrick. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// XCOFF64 layout, as GOOS=aix binaries use. See
+// https://www.ibm.com/docs/en/aix/7.3?topic=formats-xcoff-object-file-format
+// (The standard library's XCOFF reader is internal.)
+const (
+	xcoffMagic64      = 0x01F7
+	xcoffFileHdrSize  = 24
+	xcoffSectHdrSize  = 72
+	xcoffSymEntrySize = 18
+)
+
+func isXCOFF(ra io.ReaderAt) bool {
+	var magic [2]byte
+	_, err := ra.ReadAt(magic[:], 0)
+	return err == nil && binary.BigEndian.Uint16(magic[:]) == xcoffMagic64
+}
+
+type xcoffSection struct {
+	name   string
+	vaddr  uint64
+	size   uint64
+	offset uint64 // in the file
+}
+
+// xcoffFile opens a 64-bit XCOFF (AIX) binary, finding the pclntab by
+// its runtime.pclntab and runtime.epclntab symbols.
+func xcoffFile(ra io.ReaderAt, size int64) (*File, error) {
+	be := binary.BigEndian
+	var hdr [xcoffFileHdrSize]byte
+	if _, err := ra.ReadAt(hdr[:], 0); err != nil {
+		return nil, err
+	}
+	nscns := int64(be.Uint16(hdr[2:]))
+	symptr := int64(be.Uint64(hdr[8:]))
+	opthdr := int64(be.Uint16(hdr[16:]))
+	nsyms := int64(be.Uint32(hdr[20:]))
+
+	var sects []xcoffSection
+	for i := int64(0); i < nscns; i++ {
+		var sh [xcoffSectHdrSize]byte
+		if _, err := ra.ReadAt(sh[:], xcoffFileHdrSize+opthdr+i*xcoffSectHdrSize); err != nil {
+			return nil, fmt.Errorf("xcoff section header: %w", err)
+		}
+		sects = append(sects, xcoffSection{
+			name:   string(bytes.TrimRight(sh[:8], "\x00")),
+			vaddr:  be.Uint64(sh[16:]),
+			size:   be.Uint64(sh[24:]),
+			offset: be.Uint64(sh[32:]),
+		})
+	}
+	if symptr == 0 || nsyms == 0 {
+		return nil, errStripped
+	}
+
+	// The symbol table is followed by the string table, whose
+	// first 4 bytes are its size (including them).
+	syms := make([]byte, nsyms*xcoffSymEntrySize)
+	if _, err := ra.ReadAt(syms, symptr); err != nil {
+		return nil, fmt.Errorf("xcoff symbols: %w", err)
+	}
+	var strSize [4]byte
+	if _, err := ra.ReadAt(strSize[:], symptr+int64(len(syms))); err != nil {
+		return nil, fmt.Errorf("xcoff string table: %w", err)
+	}
+	strtab := make([]byte, be.Uint32(strSize[:]))
+	if _, err := ra.ReadAt(strtab, symptr+int64(len(syms))); err != nil {
+		return nil, fmt.Errorf("xcoff string table: %w", err)
+	}
+
+	f := &File{Size: size, Arch: "ppc64"}
+	addrs := map[string]uint64{}
+	for len(syms) >= xcoffSymEntrySize {
+		ent := syms[:xcoffSymEntrySize]
+		numAux := int(ent[17])
+		if off := be.Uint32(ent[8:]); off < uint32(len(strtab)) {
+			name := strtab[off:]
+			if i := bytes.IndexByte(name, 0); i >= 0 {
+				name = name[:i]
+			}
+			switch string(name) {
+			case "runtime.text", "runtime.pclntab", "runtime.epclntab":
+				addrs[string(name)] = be.Uint64(ent[0:])
+			}
+		}
+		syms = syms[min(len(syms), (1+numAux)*xcoffSymEntrySize):]
+	}
+
+	start, end := addrs["runtime.pclntab"], addrs["runtime.epclntab"]
+	if start == 0 || end <= start {
+		return nil, errors.New("no runtime.pclntab symbol found in XCOFF file")
+	}
+	for _, s := range sects {
+		if start < s.vaddr || end > s.vaddr+s.size {
+			continue
+		}
+		f.Gopclntab = make([]byte, end-start)
+		if _, err := ra.ReadAt(f.Gopclntab, int64(s.offset+start-s.vaddr)); err != nil {
+			return nil, err
+		}
+		break
+	}
+	if f.Gopclntab == nil {
+		return nil, errors.New("no XCOFF section contains runtime.pclntab")
+	}
+	f.TextOffset = addrs["runtime.text"]
+	if f.TextOffset == 0 {
+		for _, s := range sects {
+			if s.name == ".text" {
+				f.TextOffset = s.vaddr
+			}
+		}
+	}
+	return f, nil
+}